@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/disharjayanth/golangBlockchain/database"
+	"github.com/disharjayanth/golangBlockchain/node"
+	"github.com/spf13/cobra"
+)
+
+const flagOffset = "offset"
+const flagLimit = "limit"
+
+func accountCmd() *cobra.Command {
+	var accountCmd = &cobra.Command{
+		Use:   "account",
+		Short: "Interact with accounts (history)",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return incorrectUsageErr()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+
+		},
+	}
+
+	accountCmd.AddCommand(accountHistoryCmd())
+
+	return accountCmd
+}
+
+func accountHistoryCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "history <account>",
+		Short: "Lists the TXs sent or received by an account and its block rewards, newest first, as known by a running TBB node.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ip, _ := cmd.Flags().GetString(flagIP)
+			port, _ := cmd.Flags().GetUint64(flagPort)
+			offset, _ := cmd.Flags().GetUint64(flagOffset)
+			limit, _ := cmd.Flags().GetUint64(flagLimit)
+
+			res := node.AccountTXsRes{}
+			reqURL := fmt.Sprintf("http://%s:%d/account/%s/txs?offset=%d&limit=%d", ip, port, url.PathEscape(args[0]), offset, limit)
+			if err := getNodeRes(reqURL, &res); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Account '%s' TXs %d to %d of %d:\n", res.Account, res.Offset+1, res.Offset+uint64(len(res.TXs)), res.Total)
+			fmt.Println("__________________")
+			fmt.Println("")
+
+			for _, tx := range res.TXs {
+				fmt.Printf("Block %d: ", tx.BlockNumber)
+
+				switch tx.Type {
+				case database.AccountTxSent:
+					fmt.Printf("sent %s TBB to %s, fee %s TBB, TX %s\n", tx.Value, tx.Account, tx.Fee, tx.TxHash.Hex())
+				case database.AccountTxReceived:
+					fmt.Printf("received %s TBB from %s, TX %s\n", tx.Value, tx.Account, tx.TxHash.Hex())
+				default:
+					fmt.Printf("credited %s TBB block reward and fees\n", tx.Value)
+				}
+			}
+		},
+	}
+
+	cmd.Flags().String(flagIP, node.DefaultIP, "IP of the node to query")
+	cmd.Flags().Uint64(flagPort, node.DefaultHTTPPort, "HTTP port of the node to query")
+	cmd.Flags().Uint64(flagOffset, 0, "number of newest TXs to skip")
+	cmd.Flags().Uint64(flagLimit, node.DefaultAccountTXsLimit, "maximum number of TXs to list")
+
+	return cmd
+}
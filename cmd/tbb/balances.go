@@ -8,6 +8,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const flagAtBlock = "at-block"
+
 func balancesListCmd() *cobra.Command {
 	var balancesListCmd = &cobra.Command{
 		Use:   "list",
@@ -20,17 +22,29 @@ func balancesListCmd() *cobra.Command {
 			}
 			defer state.Close()
 
-			fmt.Printf("Account Balances at: %x\n", state.LatestBlockHash())
+			hash, balances := state.LatestBlockHash(), state.Balances
+
+			atBlock, _ := cmd.Flags().GetString(flagAtBlock)
+			if atBlock != "" {
+				hash, balances, err = state.BalancesAtBlock(atBlock)
+				if err != nil {
+					fmt.Fprintln(os.Stdout, err)
+					os.Exit(1)
+				}
+			}
+
+			fmt.Printf("Account Balances at: %x\n", hash)
 			fmt.Println("----------------")
 			fmt.Println("")
 
-			for account, balanace := range state.Balances {
-				fmt.Printf("%s: %d\n", account, balanace)
+			for account, balanace := range balances {
+				fmt.Printf("%s: %s TBB\n", account, balanace)
 			}
 		},
 	}
 
 	addDefaultRequiredFlags(balancesListCmd)
+	balancesListCmd.Flags().String(flagAtBlock, "", "number or hash of the block to list the balances after, the latest by default")
 
 	return balancesListCmd
 }
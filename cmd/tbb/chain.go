@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/disharjayanth/golangBlockchain/database"
+	"github.com/disharjayanth/golangBlockchain/fs"
+	"github.com/spf13/cobra"
+)
+
+const flagFromBlock = "from"
+const flagToBlock = "to"
+const flagOut = "out"
+
+func chainCmd() *cobra.Command {
+	var chainCmd = &cobra.Command{
+		Use:   "chain",
+		Short: "Inspects, exports and imports the blockchain stored in a data dir (verify|supply|export|import)",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return incorrectUsageErr()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+
+		},
+	}
+
+	chainCmd.AddCommand(chainVerifyCmd())
+	chainCmd.AddCommand(chainSupplyCmd())
+	chainCmd.AddCommand(chainExportCmd())
+	chainCmd.AddCommand(chainImportCmd())
+
+	return chainCmd
+}
+
+func chainVerifyCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "verify",
+		Short: "Checks every block.db block hash, parent, height, PoW and TXs, reporting all the problems found.",
+		Run: func(cmd *cobra.Command, args []string) {
+			report, err := database.VerifyChain(getDataDirFromCmd(cmd))
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			if report.IsValid() {
+				fmt.Printf("Verified %d blocks, no problem found\n", report.Records)
+				return
+			}
+
+			fmt.Printf("Verified %d blocks, %d problems found:\n", report.Records, len(report.Problems))
+			fmt.Println("__________________")
+			fmt.Println("")
+
+			for _, problem := range report.Problems {
+				fmt.Println(problem)
+			}
+
+			os.Exit(1)
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+
+	return cmd
+}
+
+func chainSupplyCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "supply",
+		Short: "Reports the TBB issued, circulating and remaining to be minted after a block.",
+		Run: func(cmd *cobra.Command, args []string) {
+			atBlock, _ := cmd.Flags().GetString(flagAtBlock)
+
+			state, err := database.NewStateFromDisk(getDataDirFromCmd(cmd))
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			defer state.Close()
+
+			supply, err := state.SupplyAt(atBlock)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			gen := state.Genesis()
+			fmt.Printf("Supply at block %d '%s':\n", supply.Height, supply.BlockHash.Hex())
+			fmt.Printf("\tIssued: %s TBB\n", supply.Issued)
+			fmt.Printf("\tCirculating: %s TBB\n", supply.Circulating)
+			fmt.Printf("\tRemaining: %s TBB\n", supply.Remaining)
+			fmt.Printf("\tMax supply: %s TBB\n", gen.MaxSupply)
+			fmt.Printf("\tNext block reward: %s TBB\n", gen.BlockRewardAt(supply.Height+1))
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+	cmd.Flags().String(flagAtBlock, "", "number or hash of the block to report the supply after, the latest by default")
+
+	return cmd
+}
+
+func chainExportCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "export",
+		Short: "Exports main chain blocks to a file importable into any data dir of the same genesis.",
+		Run: func(cmd *cobra.Command, args []string) {
+			from, _ := cmd.Flags().GetUint64(flagFromBlock)
+			to, _ := cmd.Flags().GetUint64(flagToBlock)
+			out, _ := cmd.Flags().GetString(flagOut)
+
+			state, err := database.NewStateFromDisk(getDataDirFromCmd(cmd))
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			defer state.Close()
+
+			if !cmd.Flags().Changed(flagToBlock) {
+				to = state.LatestBlock().Header.Number
+			}
+
+			f, err := os.Create(fs.ExpandPath(out))
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			defer f.Close()
+
+			writer := bufio.NewWriter(f)
+			header, err := state.ExportChain(writer, from, to)
+			if err == nil {
+				err = writer.Flush()
+			}
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Exported blocks %d to %d of chain '%s' to %s\n", header.From, header.To, header.ChainID, out)
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+	cmd.Flags().Uint64(flagFromBlock, 0, "number of the first block to export")
+	cmd.Flags().Uint64(flagToBlock, 0, "number of the last block to export, the latest one by default")
+	cmd.Flags().String(flagOut, "", "path of the export file to write")
+	cmd.MarkFlagRequired(flagOut)
+
+	return cmd
+}
+
+func chainImportCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "import <file>",
+		Short: "Validates and adds the blocks of an export file, skipping the ones already imported.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			state, err := database.NewStateFromDisk(getDataDirFromCmd(cmd))
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			defer state.Close()
+
+			f, err := os.Open(fs.ExpandPath(args[0]))
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			defer f.Close()
+
+			report, err := state.ImportChain(f)
+			fmt.Printf("Imported %d blocks, skipped %d already imported blocks, kept %d blocks of a lighter branch aside\n", report.Imported, report.Skipped, report.SideBlocks)
+			if err != nil {
+				fmt.Println(err)
+				state.Close()
+				os.Exit(1)
+			}
+
+			fmt.Printf("Latest block: %d '%s'\n", state.LatestBlock().Header.Number, state.LatestBlockHash().Hex())
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+
+	return cmd
+}
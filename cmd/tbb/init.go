@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/disharjayanth/golangBlockchain/database"
+	"github.com/disharjayanth/golangBlockchain/fs"
+	"github.com/spf13/cobra"
+)
+
+const flagGenesis = "genesis"
+const flagForce = "force"
+
+func initCmd() *cobra.Command {
+	var initCmd = &cobra.Command{
+		Use:   "init",
+		Short: "Creates the node data dir with a custom genesis file.",
+		Run: func(cmd *cobra.Command, args []string) {
+			genesisPath, _ := cmd.Flags().GetString(flagGenesis)
+			force, _ := cmd.Flags().GetBool(flagForce)
+
+			genesis, err := ioutil.ReadFile(fs.ExpandPath(genesisPath))
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			gen, err := database.InitDataDir(getDataDirFromCmd(cmd), genesis, force)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Initialised chain '%s' in %s\n", gen.ChainID, getDataDirFromCmd(cmd))
+			fmt.Printf("Genesis hash: %s\n", gen.Hash().Hex())
+		},
+	}
+
+	addDefaultRequiredFlags(initCmd)
+	initCmd.Flags().String(flagGenesis, "", "path of the genesis JSON file")
+	initCmd.MarkFlagRequired(flagGenesis)
+	initCmd.Flags().Bool(flagForce, false, "overwrite an existing chain and all its blocks")
+
+	return initCmd
+}
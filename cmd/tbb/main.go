@@ -1,10 +1,14 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
 
 	"github.com/disharjayanth/golangBlockchain/fs"
+	"github.com/disharjayanth/golangBlockchain/node"
 	"github.com/spf13/cobra"
 )
 
@@ -27,6 +31,32 @@ func getDataDirFromCmd(cmd *cobra.Command) string {
 	return fs.ExpandPath(dataDir)
 }
 
+// getNodeRes queries a running node and decodes its JSON response,
+// or returns the error the node responded with
+func getNodeRes(url string, res interface{}) error {
+	httpRes, err := http.Get(url)
+	if err != nil {
+		return fmt.Errorf("error while querying %s: %w", url, err)
+	}
+	defer httpRes.Body.Close()
+
+	resJSON, err := ioutil.ReadAll(httpRes.Body)
+	if err != nil {
+		return err
+	}
+
+	if httpRes.StatusCode != http.StatusOK {
+		errRes := node.ErrRes{}
+		if err := json.Unmarshal(resJSON, &errRes); err != nil {
+			return fmt.Errorf("node responded with status %d", httpRes.StatusCode)
+		}
+
+		return fmt.Errorf(errRes.Error)
+	}
+
+	return json.Unmarshal(resJSON, res)
+}
+
 func main() {
 	tbbCmd := &cobra.Command{
 		Use:   "tbb",
@@ -39,10 +69,16 @@ func main() {
 		},
 	}
 
+	tbbCmd.AddCommand(initCmd())
 	tbbCmd.AddCommand(migrateCmd())
 	tbbCmd.AddCommand(versionCmd)
 	tbbCmd.AddCommand(runCmd())
 	tbbCmd.AddCommand(balancesCmd())
+	tbbCmd.AddCommand(walletCmd())
+	tbbCmd.AddCommand(txCmd())
+	tbbCmd.AddCommand(accountCmd())
+	tbbCmd.AddCommand(chainCmd())
+	tbbCmd.AddCommand(multisigCmd())
 
 	if err := tbbCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stdout, err)
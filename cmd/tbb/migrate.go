@@ -2,66 +2,161 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"time"
+	"os"
 
 	"github.com/disharjayanth/golangBlockchain/database"
+	"github.com/disharjayanth/golangBlockchain/fs"
 	"github.com/disharjayanth/golangBlockchain/node"
+	"github.com/disharjayanth/golangBlockchain/wallet"
 	"github.com/spf13/cobra"
 )
 
+const flagLedger = "ledger"
+const flagAccount = "account"
+const flagDifficulty = "difficulty"
+const flagDryRun = "dry-run"
+
 var migrateCmd = func() *cobra.Command {
 	var migrateCmd = &cobra.Command{
 		Use:   "migrate",
-		Short: "Migrates the blockchain database according to new business rules.",
+		Short: "Migrates a legacy tx.db ledger and its genesis into a new data dir.",
+		Long: `Migrates a legacy tx.db ledger and its genesis into a new data dir.
+
+Every legacy block is converted into a block of the same time, its transfers
+signed by the keystore accounts replacing the legacy senders and its reward
+TXs turned into the miner block reward, then sealed with a new PoW.
+Self and zero-value transfers move no TBB and are dropped.
+The migrated balances are checked against the legacy ones.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			miner, _ := cmd.Flags().GetString(flagMiner)
-			ip, _ := cmd.Flags().GetString(flagIP)
-			port, _ := cmd.Flags().GetUint64(flagPort)
-
-			peer := node.NewPeerNode(
-				"127.0.0.1",
-				8080,
-				true,
-				database.NewAccount("andrej"),
-				false,
-			)
-
-			n := node.New(getDataDirFromCmd(cmd), ip, port, database.NewAccount(miner), peer)
-
-			n.AddPendingTX(database.NewTx("andrej", "andrej", 3, ""), peer)
-			n.AddPendingTX(database.NewTx("andrej", "babayaga", 2000, ""), peer)
-			n.AddPendingTX(database.NewTx("babayaga", "andrej", 1, ""), peer)
-			n.AddPendingTX(database.NewTx("babayaga", "caesar", 1000, ""), peer)
-			n.AddPendingTX(database.NewTx("babayaga", "andrej", 50, ""), peer)
-
-			ctx, closeNode := context.WithTimeout(context.Background(), time.Minute*15)
-
-			go func() {
-				ticker := time.NewTicker(time.Second * 10)
-
-				for {
-					select {
-					case <-ticker.C:
-						if !n.LatestBlockHash().IsEmpty() {
-							closeNode()
-							return
-						}
-					}
-				}
-			}()
-
-			err := n.Run(ctx)
+			dataDir := getDataDirFromCmd(cmd)
+			ledgerPath, _ := cmd.Flags().GetString(flagLedger)
+			genesisPath, _ := cmd.Flags().GetString(flagGenesis)
+			renamed, _ := cmd.Flags().GetStringToString(flagAccount)
+			difficulty, _ := cmd.Flags().GetUint32(flagDifficulty)
+			dryRun, _ := cmd.Flags().GetBool(flagDryRun)
+			force, _ := cmd.Flags().GetBool(flagForce)
+
+			ledger, err := database.LoadLegacyLedger(fs.ExpandPath(ledgerPath))
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			legacyGen, err := database.LoadGenesis(fs.ExpandPath(genesisPath))
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			legacyBalances, err := ledger.Replay(legacyGen.Balances)
 			if err != nil {
 				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			accounts := make(map[database.Account]database.Account)
+			for legacy, account := range renamed {
+				accounts[database.NewAccount(legacy)] = database.NewAccount(account)
+			}
+
+			gen, err := migrateLegacyGenesis(legacyGen, ledger, accounts, difficulty)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			// the senders of the migrated TXs must all be encrypted with the same password
+			password := getPassPhrase("Please enter the password of the migrated accounts:", false)
+			keystoreDir := wallet.GetKeystoreDirPath(dataDir)
+
+			state, err := openMigratedState(dataDir, gen, dryRun, force)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			defer state.Close()
+
+			sign := func(tx database.Tx) (database.SignedTx, error) {
+				return wallet.SignTxWithKeystoreAccount(tx, tx.From, password, keystoreDir)
+			}
+			seal := func(b database.Block) (database.Block, error) {
+				return node.Seal(context.Background(), b)
+			}
+
+			if err := database.MigrateLegacyLedger(state, ledger, legacyBalances, accounts, sign, seal); err != nil {
+				fmt.Println(err)
+				state.Close()
+				os.Exit(1)
+			}
+
+			if dryRun {
+				fmt.Printf("Dry run: %d legacy blocks can be migrated, nothing was written\n", len(ledger))
+			} else {
+				fmt.Printf("Migrated %d legacy blocks into %s\n", len(ledger), dataDir)
 			}
+			fmt.Printf("Genesis hash: %s\n", gen.Hash().Hex())
+			fmt.Printf("Latest block: %d '%s'\n", state.LatestBlock().Header.Number, state.LatestBlockHash().Hex())
 		},
 	}
 
 	addDefaultRequiredFlags(migrateCmd)
-	migrateCmd.Flags().String(flagMiner, node.DefaultMiner, "miner account of this node to receive block rewards")
-	migrateCmd.Flags().String(flagIP, node.DefaultIP, "exposed IP for communication with peers")
-	migrateCmd.Flags().Uint64(flagPort, node.DefaultHTTPPort, "exposed HTTP port for communication with peers")
+	migrateCmd.Flags().String(flagLedger, "", "path of the legacy tx.db ledger")
+	migrateCmd.Flags().String(flagGenesis, "", "path of the legacy genesis JSON file")
+	migrateCmd.Flags().StringToString(flagAccount, nil, "keystore account replacing a legacy account, as legacy=0x..., repeatable")
+	migrateCmd.Flags().Uint32(flagDifficulty, database.DefaultDifficulty, "PoW difficulty of the migrated chain")
+	migrateCmd.Flags().Bool(flagDryRun, false, "migrate in memory only, without writing the data dir")
+	migrateCmd.Flags().Bool(flagForce, false, "overwrite an existing chain of the data dir")
+	migrateCmd.MarkFlagRequired(flagLedger)
+	migrateCmd.MarkFlagRequired(flagGenesis)
 
 	return migrateCmd
 }
+
+// migrateLegacyGenesis renames the legacy genesis accounts and sets
+// the block reward the legacy blocks minted
+func migrateLegacyGenesis(legacy database.Genesis, ledger database.LegacyLedger, accounts map[database.Account]database.Account, difficulty uint32) (database.Genesis, error) {
+	blockReward, err := ledger.BlockReward()
+	if err != nil {
+		return database.Genesis{}, err
+	}
+
+	gen := legacy
+	gen.Difficulty = difficulty
+	gen.BlockReward = blockReward
+	gen.Balances = make(map[database.Account]database.Amount)
+
+	for account, balance := range legacy.Balances {
+		if renamed, ok := accounts[account]; ok {
+			account = renamed
+		}
+
+		merged, err := gen.Balances[account].Add(balance)
+		if err != nil {
+			return database.Genesis{}, fmt.Errorf("genesis '%s' balance: %w", account, err)
+		}
+		gen.Balances[account] = merged
+	}
+
+	return gen, gen.Validate()
+}
+
+// openMigratedState initialises the data dir with the migrated genesis,
+// or only keeps the migrated blocks in memory on a dry run
+func openMigratedState(dataDir string, gen database.Genesis, dryRun bool, force bool) (*database.State, error) {
+	if dryRun {
+		return database.NewStateFromStore(gen, database.NewMemoryBlockStore())
+	}
+
+	genesisJSON, err := json.MarshalIndent(gen, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := database.InitDataDir(dataDir, genesisJSON, force); err != nil {
+		return nil, err
+	}
+
+	return database.NewStateFromDisk(dataDir)
+}
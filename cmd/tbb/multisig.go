@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/disharjayanth/golangBlockchain/database"
+	"github.com/disharjayanth/golangBlockchain/fs"
+	"github.com/disharjayanth/golangBlockchain/node"
+	"github.com/disharjayanth/golangBlockchain/wallet"
+	"github.com/spf13/cobra"
+)
+
+const flagThreshold = "threshold"
+const flagPubKey = "pub-key"
+const flagMultisig = "multisig"
+const flagNonce = "nonce"
+
+func multisigCmd() *cobra.Command {
+	var multisigCmd = &cobra.Command{
+		Use:   "multisig",
+		Short: "Creates M-of-N multisig accounts and signs their TXs offline (new|new-tx|sign|combine|submit)",
+		Long: `Creates M-of-N multisig accounts and signs their TXs offline.
+
+A multisig TX is created in a file with 'new-tx', then each key holder
+adds their signature with 'sign', either to the same file in turn or to
+their own copy merged afterwards with 'combine'. Once it holds the
+threshold of signatures it's sent to a node with 'submit'.`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return incorrectUsageErr()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+
+		},
+	}
+
+	multisigCmd.AddCommand(multisigNewCmd())
+	multisigCmd.AddCommand(multisigNewTxCmd())
+	multisigCmd.AddCommand(multisigSignCmd())
+	multisigCmd.AddCommand(multisigCombineCmd())
+	multisigCmd.AddCommand(multisigSubmitCmd())
+
+	return multisigCmd
+}
+
+func multisigNewCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "new",
+		Short: "Creates a multisig account from the public keys of its holders, printed by 'tbb wallet pub-key'.",
+		Run: func(cmd *cobra.Command, args []string) {
+			threshold, _ := cmd.Flags().GetUint32(flagThreshold)
+			hexKeys, _ := cmd.Flags().GetStringArray(flagPubKey)
+			out, _ := cmd.Flags().GetString(flagOut)
+
+			pubKeys := make([][]byte, len(hexKeys))
+			for i, hexKey := range hexKeys {
+				pubKey, err := hex.DecodeString(hexKey)
+				if err != nil {
+					fmt.Printf("invalid public key '%s': %s\n", hexKey, err)
+					os.Exit(1)
+				}
+				pubKeys[i] = pubKey
+			}
+
+			multisig, err := database.NewMultisig(threshold, pubKeys)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			if err := writeJSONFile(out, multisig); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("New %d-of-%d multisig account: %s\n", multisig.Threshold, len(multisig.PubKeys), multisig.Account())
+			fmt.Printf("Saved in: %s\n", out)
+		},
+	}
+
+	cmd.Flags().Uint32(flagThreshold, 0, "number of signatures a TX of the account requires")
+	cmd.Flags().StringArray(flagPubKey, nil, "hex compressed public key of an account holder, repeatable")
+	cmd.Flags().String(flagOut, "", "path of the multisig account file to write")
+	cmd.MarkFlagRequired(flagThreshold)
+	cmd.MarkFlagRequired(flagPubKey)
+	cmd.MarkFlagRequired(flagOut)
+
+	return cmd
+}
+
+func multisigNewTxCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "new-tx",
+		Short: "Creates an unsigned TX of a multisig account in a file to pass around its key holders.",
+		Run: func(cmd *cobra.Command, args []string) {
+			multisigPath, _ := cmd.Flags().GetString(flagMultisig)
+			to, _ := cmd.Flags().GetString(flagTo)
+			valueFlag, _ := cmd.Flags().GetString(flagValue)
+			feeFlag, _ := cmd.Flags().GetString(flagFee)
+			nonce, _ := cmd.Flags().GetUint(flagNonce)
+			data, _ := cmd.Flags().GetString(flagData)
+			out, _ := cmd.Flags().GetString(flagOut)
+			lockHeight, _ := cmd.Flags().GetUint64(flagLockHeight)
+			lockTime, _ := cmd.Flags().GetUint64(flagLockTime)
+
+			var multisig database.Multisig
+			if err := readJSONFile(multisigPath, &multisig); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			value, err := database.ParseAmount(valueFlag)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fee, err := database.ParseAmount(feeFlag)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			tx := database.NewTx(multisig.Account(), database.NewAccount(to), value, fee, nonce, data)
+			tx.LockHeight = lockHeight
+			tx.LockTime = lockTime
+			if err := tx.Validate(); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			if err := writeJSONFile(out, database.NewMultisigTx(tx, multisig)); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("TX of '%s' requiring %d signatures saved in: %s\n", tx.From, multisig.Threshold, out)
+		},
+	}
+
+	cmd.Flags().String(flagMultisig, "", "path of the multisig account file")
+	cmd.Flags().String(flagTo, "", "recipient account")
+	cmd.Flags().String(flagValue, "", "amount of TBB to send, with up to 8 decimals")
+	cmd.Flags().String(flagFee, "0", "fee in TBB paid to the miner of the block including the TX, with up to 8 decimals")
+	cmd.Flags().Uint(flagNonce, 0, "next nonce of the multisig account, the number of TXs it sent plus 1")
+	cmd.Flags().String(flagData, "", "arbitrary TX data")
+	cmd.Flags().String(flagOut, "", "path of the TX file to write")
+	addTxLockFlags(cmd)
+	cmd.MarkFlagRequired(flagMultisig)
+	cmd.MarkFlagRequired(flagTo)
+	cmd.MarkFlagRequired(flagValue)
+	cmd.MarkFlagRequired(flagNonce)
+	cmd.MarkFlagRequired(flagOut)
+
+	return cmd
+}
+
+func multisigSignCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "sign <tx-file>",
+		Short: "Adds the signature of a keystore account holding one of the multisig keys to a TX file.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			account, _ := cmd.Flags().GetString(flagAccount)
+			out, _ := cmd.Flags().GetString(flagOut)
+			if out == "" {
+				out = args[0]
+			}
+
+			var tx database.SignedTx
+			if err := readJSONFile(args[0], &tx); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			password := getPassPhrase(fmt.Sprintf("Please enter the password of the '%s' account:", account), false)
+
+			tx, err := wallet.SignMultisigTxWithKeystoreAccount(tx, database.NewAccount(account), password, wallet.GetKeystoreDirPath(getDataDirFromCmd(cmd)))
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			if err := writeJSONFile(out, tx); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("TX has %d of the %d signatures required, saved in: %s\n", len(tx.Sigs), tx.Multisig.Threshold, out)
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+	cmd.Flags().String(flagAccount, "", "keystore account signing the TX")
+	cmd.Flags().String(flagOut, "", "path of the signed TX file to write, the TX file by default")
+	cmd.MarkFlagRequired(flagAccount)
+
+	return cmd
+}
+
+func multisigCombineCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "combine <tx-file>...",
+		Short: "Merges the signatures of copies of the same multisig TX signed separately.",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString(flagOut)
+
+			txs := make([]database.SignedTx, len(args))
+			for i, path := range args {
+				if err := readJSONFile(path, &txs[i]); err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+			}
+
+			tx, err := database.CombineMultisigTXs(txs)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			if err := writeJSONFile(out, tx); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("TX has %d of the %d signatures required, saved in: %s\n", len(tx.Sigs), tx.Multisig.Threshold, out)
+		},
+	}
+
+	cmd.Flags().String(flagOut, "", "path of the combined TX file to write")
+	cmd.MarkFlagRequired(flagOut)
+
+	return cmd
+}
+
+func multisigSubmitCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "submit <tx-file>",
+		Short: "Submits a multisig TX holding enough signatures to a running TBB node.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ip, _ := cmd.Flags().GetString(flagIP)
+			port, _ := cmd.Flags().GetUint64(flagPort)
+
+			var tx database.SignedTx
+			if err := readJSONFile(args[0], &tx); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			if _, err := tx.IsAuthentic(); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			err := postTxAddReq(fmt.Sprintf("http://%s:%d/tx/add", ip, port), node.TxAddReq{SignedTx: &tx})
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			txHash, err := tx.Hash()
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("TX %s successfully added to the node's mempool\n", txHash.Hex())
+		},
+	}
+
+	cmd.Flags().String(flagIP, node.DefaultIP, "IP of the node to submit the TX to")
+	cmd.Flags().Uint64(flagPort, node.DefaultHTTPPort, "HTTP port of the node to submit the TX to")
+
+	return cmd
+}
+
+func readJSONFile(path string, v interface{}) error {
+	content, err := ioutil.ReadFile(fs.ExpandPath(path))
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("error while decoding %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(fs.ExpandPath(path), content, 0644)
+}
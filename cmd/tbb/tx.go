@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/disharjayanth/golangBlockchain/database"
+	"github.com/disharjayanth/golangBlockchain/node"
+	"github.com/spf13/cobra"
+)
+
+const flagFrom = "from"
+const flagTo = "to"
+const flagValue = "value"
+const flagFee = "fee"
+const flagData = "data"
+const flagLockHeight = "lock-height"
+const flagLockTime = "lock-time"
+
+func txCmd() *cobra.Command {
+	var txCmd = &cobra.Command{
+		Use:   "tx",
+		Short: "Interact with transactions (add|show)",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return incorrectUsageErr()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+
+		},
+	}
+
+	txCmd.AddCommand(txAddCmd())
+	txCmd.AddCommand(txShowCmd())
+
+	return txCmd
+}
+
+func txAddCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "add",
+		Short: "Submits a new TX to a running TBB node, signed with the sender's keystore account.",
+		Run: func(cmd *cobra.Command, args []string) {
+			ip, _ := cmd.Flags().GetString(flagIP)
+			port, _ := cmd.Flags().GetUint64(flagPort)
+			from, _ := cmd.Flags().GetString(flagFrom)
+			to, _ := cmd.Flags().GetString(flagTo)
+			valueFlag, _ := cmd.Flags().GetString(flagValue)
+			feeFlag, _ := cmd.Flags().GetString(flagFee)
+			data, _ := cmd.Flags().GetString(flagData)
+			lockHeight, _ := cmd.Flags().GetUint64(flagLockHeight)
+			lockTime, _ := cmd.Flags().GetUint64(flagLockTime)
+
+			value, err := database.ParseAmount(valueFlag)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fee, err := database.ParseAmount(feeFlag)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			password := getPassPhrase(fmt.Sprintf("Please enter the password of the '%s' account:", from), false)
+
+			req := node.TxAddReq{
+				From:    from,
+				FromPwd: password,
+				To:      to,
+				Value:   value,
+				Fee:     fee,
+				Data:    data,
+
+				LockHeight: lockHeight,
+				LockTime:   lockTime,
+			}
+
+			err = postTxAddReq(fmt.Sprintf("http://%s:%d/tx/add", ip, port), req)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fmt.Println("TX successfully added to the node's mempool")
+		},
+	}
+
+	cmd.Flags().String(flagIP, node.DefaultIP, "IP of the node to submit the TX to")
+	cmd.Flags().Uint64(flagPort, node.DefaultHTTPPort, "HTTP port of the node to submit the TX to")
+	cmd.Flags().String(flagFrom, "", "sender account, must be in the node's keystore")
+	cmd.Flags().String(flagTo, "", "recipient account")
+	cmd.Flags().String(flagValue, "", "amount of TBB to send, with up to 8 decimals")
+	cmd.Flags().String(flagFee, "0", "fee in TBB paid to the miner of the block including the TX, with up to 8 decimals")
+	cmd.Flags().String(flagData, "", "arbitrary TX data")
+	addTxLockFlags(cmd)
+	cmd.MarkFlagRequired(flagFrom)
+	cmd.MarkFlagRequired(flagTo)
+	cmd.MarkFlagRequired(flagValue)
+
+	return cmd
+}
+
+func txShowCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "show <tx hash>",
+		Short: "Shows whether a TX is pending or mined, and its confirmations, as known by a running TBB node.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ip, _ := cmd.Flags().GetString(flagIP)
+			port, _ := cmd.Flags().GetUint64(flagPort)
+
+			txRes := node.TxRes{}
+			err := getNodeRes(fmt.Sprintf("http://%s:%d/tx/%s", ip, port, args[0]), &txRes)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("TX: %s\n", txRes.Hash.Hex())
+			fmt.Printf("Status: %s\n", txRes.Status)
+			fmt.Printf("From: %s\n", txRes.Tx.From)
+			fmt.Printf("To: %s\n", txRes.Tx.To)
+			fmt.Printf("Value: %s TBB\n", txRes.Tx.Value)
+			fmt.Printf("Fee: %s TBB\n", txRes.Tx.Fee)
+			fmt.Printf("Nonce: %d\n", txRes.Tx.Nonce)
+
+			if txRes.Lock != nil {
+				if txRes.Lock.Height > 0 {
+					fmt.Printf("Unlocks at height: %d\n", txRes.Lock.Height)
+				}
+				if txRes.Lock.Time > 0 {
+					fmt.Printf("Unlocks at median block time: %d (%s)\n", txRes.Lock.Time, time.Unix(int64(txRes.Lock.Time), 0).UTC().Format(time.RFC3339))
+				}
+				fmt.Printf("Locked: %t\n", txRes.Lock.Locked)
+			}
+
+			if txRes.Location != nil {
+				fmt.Printf("Block: %s\n", txRes.Location.BlockHash.Hex())
+				fmt.Printf("Height: %d\n", txRes.Location.BlockNumber)
+				fmt.Printf("Position: %d\n", txRes.Location.Index)
+				fmt.Printf("Confirmations: %d\n", txRes.Confirmations)
+			}
+		},
+	}
+
+	cmd.Flags().String(flagIP, node.DefaultIP, "IP of the node to query")
+	cmd.Flags().Uint64(flagPort, node.DefaultHTTPPort, "HTTP port of the node to query")
+
+	return cmd
+}
+
+func postTxAddReq(url string, req node.TxAddReq) error {
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := http.Post(url, "application/json", bytes.NewReader(reqJSON))
+	if err != nil {
+		return fmt.Errorf("error while submitting TX to %s: %w", url, err)
+	}
+	defer res.Body.Close()
+
+	resJSON, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		errRes := node.ErrRes{}
+		if err := json.Unmarshal(resJSON, &errRes); err != nil {
+			return fmt.Errorf("node responded with status %d", res.StatusCode)
+		}
+
+		return fmt.Errorf("node rejected the TX: %s", errRes.Error)
+	}
+
+	return nil
+}
+
+func addTxLockFlags(cmd *cobra.Command) {
+	cmd.Flags().Uint64(flagLockHeight, 0, "lowest block height able to include the TX, 0 for none")
+	cmd.Flags().Uint64(flagLockTime, 0, "Unix time the median time of the latest blocks must reach before the TX is mined, 0 for none")
+}
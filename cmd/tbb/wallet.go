@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/disharjayanth/golangBlockchain/database"
+	"github.com/disharjayanth/golangBlockchain/wallet"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// stdinReader is shared so piped input isn't lost to a discarded buffer between prompts
+var stdinReader = bufio.NewReader(os.Stdin)
+
+func walletCmd() *cobra.Command {
+	var walletCmd = &cobra.Command{
+		Use:   "wallet",
+		Short: "Manages blockchain accounts and keys.",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return incorrectUsageErr()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+
+		},
+	}
+
+	walletCmd.AddCommand(walletNewAccountCmd())
+	walletCmd.AddCommand(walletPubKeyCmd())
+
+	return walletCmd
+}
+
+func walletNewAccountCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "new-account",
+		Short: "Creates a new account with a new set of a elliptic-curve Private + Public keys.",
+		Run: func(cmd *cobra.Command, args []string) {
+			password := getPassPhrase("Please enter a password to encrypt the new wallet:", true)
+
+			acc, err := wallet.NewKeystoreAccount(getDataDirFromCmd(cmd), password)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("New account created: %s\n", acc)
+			fmt.Printf("Saved in: %s\n", wallet.GetKeystoreDirPath(getDataDirFromCmd(cmd)))
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+
+	return cmd
+}
+
+func walletPubKeyCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "pub-key",
+		Short: "Prints the compressed public key of a keystore account, to share for a multisig account.",
+		Run: func(cmd *cobra.Command, args []string) {
+			account, _ := cmd.Flags().GetString(flagAccount)
+			password := getPassPhrase(fmt.Sprintf("Please enter the password of the '%s' account:", account), false)
+
+			pubKey, err := wallet.GetKeystoreAccountPubKey(database.NewAccount(account), password, wallet.GetKeystoreDirPath(getDataDirFromCmd(cmd)))
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("%x\n", pubKey)
+		},
+	}
+
+	addDefaultRequiredFlags(cmd)
+	cmd.Flags().String(flagAccount, "", "keystore account to print the public key of")
+	cmd.MarkFlagRequired(flagAccount)
+
+	return cmd
+}
+
+// getPassPhrase prompts for a password without echo when attached to a terminal,
+// otherwise reads it as a line from stdin
+func getPassPhrase(prompt string, confirmation bool) string {
+	fmt.Println(prompt)
+
+	password, err := readPassword()
+	if err != nil {
+		fmt.Printf("Failed to read password: %s\n", err)
+		os.Exit(1)
+	}
+
+	if confirmation {
+		fmt.Println("Repeat password:")
+		confirm, err := readPassword()
+		if err != nil {
+			fmt.Printf("Failed to read password confirmation: %s\n", err)
+			os.Exit(1)
+		}
+
+		if password != confirm {
+			fmt.Println("Passwords do not match")
+			os.Exit(1)
+		}
+	}
+
+	return password
+}
+
+func readPassword() (string, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		password, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+
+		return string(password), err
+	}
+
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,251 @@
+package database
+
+import (
+	"fmt"
+)
+
+const (
+	AccountTxSent     = "sent"
+	AccountTxReceived = "received"
+	AccountTxReward   = "reward"
+)
+
+// accountTxTypes are the AccountTx types by their binary code
+var accountTxTypes = []string{AccountTxSent, AccountTxReceived, AccountTxReward}
+
+// AccountTx is a main chain TX an account sent or received,
+// or the reward and fees it was credited as a block miner.
+// TxHash and TxIndex are empty for a block reward.
+type AccountTx struct {
+	Type        string  `json:"type"`
+	BlockHash   Hash    `json:"block_hash"`
+	BlockNumber uint64  `json:"block_number"`
+	TxHash      Hash    `json:"tx_hash"`
+	TxIndex     uint64  `json:"tx_index"`
+	Account     Account `json:"counterparty"`
+	Value       Amount  `json:"value"`
+	Fee         Amount  `json:"fee"`
+}
+
+// accountIndexEntry lists the account TXs of a main chain block
+type accountIndexEntry struct {
+	number   uint64
+	hash     Hash
+	accounts []Account
+	txs      []AccountTx
+}
+
+func newAccountIndexEntry(b Block, hash Hash, blockReward Amount) (accountIndexEntry, error) {
+	entry := accountIndexEntry{number: b.Header.Number, hash: hash}
+
+	add := func(account Account, tx AccountTx) {
+		tx.BlockHash = hash
+		tx.BlockNumber = b.Header.Number
+		entry.accounts = append(entry.accounts, account)
+		entry.txs = append(entry.txs, tx)
+	}
+
+	for i, tx := range b.TXs {
+		txHash, err := tx.Hash()
+		if err != nil {
+			return accountIndexEntry{}, err
+		}
+
+		add(tx.From, AccountTx{Type: AccountTxSent, TxHash: txHash, TxIndex: uint64(i), Account: tx.To, Value: tx.Value, Fee: tx.Fee})
+		add(tx.To, AccountTx{Type: AccountTxReceived, TxHash: txHash, TxIndex: uint64(i), Account: tx.From, Value: tx.Value})
+	}
+
+	fees, err := b.FeesReward()
+	if err == nil {
+		blockReward, err = blockReward.Add(fees)
+	}
+	if err != nil {
+		return accountIndexEntry{}, err
+	}
+	add(b.Header.Miner, AccountTx{Type: AccountTxReward, Value: blockReward})
+
+	return entry, nil
+}
+
+func (e accountIndexEntry) MarshalBinary() ([]byte, error) {
+	enc := newBlockLogEncoder(e.number, e.hash)
+
+	enc.length(len(e.txs))
+	for i, tx := range e.txs {
+		code := -1
+		for c, txType := range accountTxTypes {
+			if txType == tx.Type {
+				code = c
+			}
+		}
+		if code < 0 {
+			return nil, fmt.Errorf("unknown account TX type '%s'", tx.Type)
+		}
+
+		enc.string(string(e.accounts[i]))
+		enc.uint32(uint32(code))
+		enc.hash(tx.TxHash)
+		enc.uint64(tx.TxIndex)
+		enc.string(string(tx.Account))
+		enc.uint64(uint64(tx.Value))
+		enc.uint64(uint64(tx.Fee))
+	}
+
+	return enc.buf.Bytes(), nil
+}
+
+func (e *accountIndexEntry) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	e.number = d.uint64()
+	e.hash = d.hash()
+
+	n := d.length()
+	e.accounts = make([]Account, 0, n)
+	e.txs = make([]AccountTx, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		e.accounts = append(e.accounts, Account(d.string()))
+
+		code := d.uint32()
+		if int(code) >= len(accountTxTypes) {
+			return fmt.Errorf("unknown account TX type code %d", code)
+		}
+
+		tx := AccountTx{Type: accountTxTypes[code], BlockHash: e.hash, BlockNumber: e.number}
+		tx.TxHash = d.hash()
+		tx.TxIndex = d.uint64()
+		tx.Account = Account(d.string())
+		tx.Value = Amount(d.uint64())
+		tx.Fee = Amount(d.uint64())
+		e.txs = append(e.txs, tx)
+	}
+
+	return d.finish()
+}
+
+// accountIndex persists the account TXs of every main chain block to
+// accountindex.db and keeps the history of every account in memory
+type accountIndex struct {
+	log     *blockLog
+	genesis Genesis
+	// every account TXs, oldest first
+	history map[Account][]AccountTx
+}
+
+func newMemoryAccountIndex(gen Genesis) *accountIndex {
+	return &accountIndex{newMemoryBlockLog(), gen, make(map[Account][]AccountTx)}
+}
+
+// openAccountIndex loads the history of every account. The index is cut at
+// its first unreadable entry, the missing entries being written again
+// as the blocks are replayed.
+func openAccountIndex(path string, gen Genesis) (*accountIndex, error) {
+	log, err := openBlockLog(path)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &accountIndex{log, gen, make(map[Account][]AccountTx)}
+
+	for number := uint64(0); number < log.len(); number++ {
+		entry, err := idx.read(number)
+		if err != nil {
+			fmt.Printf("WARNING: %s, account TXs are indexed again from block '%d'\n", err, number)
+			if err := log.truncate(number); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		idx.record(entry)
+	}
+
+	return idx, nil
+}
+
+func (idx *accountIndex) hashAt(number uint64) (Hash, bool) {
+	return idx.log.hashAt(number)
+}
+
+func (idx *accountIndex) read(number uint64) (accountIndexEntry, error) {
+	payload, err := idx.log.read(number)
+	if err != nil {
+		return accountIndexEntry{}, err
+	}
+
+	var entry accountIndexEntry
+	if err := entry.UnmarshalBinary(payload); err != nil {
+		return accountIndexEntry{}, fmt.Errorf("error while decoding account index entry of block '%d': %w", number, err)
+	}
+
+	return entry, nil
+}
+
+func (idx *accountIndex) record(entry accountIndexEntry) {
+	for i, account := range entry.accounts {
+		idx.history[account] = append(idx.history[account], entry.txs[i])
+	}
+}
+
+// append indexes the account TXs of the block following the latest indexed one
+func (idx *accountIndex) append(b Block, hash Hash) error {
+	entry, err := newAccountIndexEntry(b, hash, idx.genesis.BlockRewardAt(b.Header.Number))
+	if err != nil {
+		return err
+	}
+
+	payload, err := entry.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if err := idx.log.append(entry.number, entry.hash, payload); err != nil {
+		return err
+	}
+
+	idx.record(entry)
+
+	return nil
+}
+
+// truncate removes the account TXs of blocks from the number on
+func (idx *accountIndex) truncate(from uint64) error {
+	for number := from; number < idx.log.len(); number++ {
+		entry, err := idx.read(number)
+		if err != nil {
+			return err
+		}
+
+		for _, account := range entry.accounts {
+			history := idx.history[account]
+			for len(history) > 0 && history[len(history)-1].BlockNumber >= from {
+				history = history[:len(history)-1]
+			}
+
+			if len(history) == 0 {
+				delete(idx.history, account)
+			} else {
+				idx.history[account] = history
+			}
+		}
+	}
+
+	return idx.log.truncate(from)
+}
+
+func (idx *accountIndex) close() error {
+	return idx.log.close()
+}
+
+// GetAccountTXs returns a page of the account main chain TXs and block rewards,
+// newest first, along with their total count
+func (s *State) GetAccountTXs(account Account, offset uint64, limit uint64) ([]AccountTx, uint64) {
+	history := s.indexes.account.history[account]
+	total := uint64(len(history))
+
+	page := make([]AccountTx, 0)
+	for i := offset; i < total && uint64(len(page)) < limit; i++ {
+		page = append(page, history[total-1-i])
+	}
+
+	return page, total
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func TestGetAccountTXs(t *testing.T) {
+	privKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc := PubKeyToAccount(privKey.PubKey())
+
+	state, dataDir := createTestState(t, fmt.Sprintf(`{"chain_id": "test-chain", "difficulty": %d, "balances": {"%s": 1000}}`, testDifficulty, acc))
+
+	mineTestBlock(t, state, "babayaga", []SignedTx{signTestTx(t, privKey, NewTx(acc, "babayaga", 10, 1, 1, ""))})
+	mineTestBlock(t, state, acc, []SignedTx{signTestTx(t, privKey, NewTx(acc, "caesar", 20, 2, 2, ""))})
+	state.Close()
+
+	state, err = NewStateFromDisk(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+
+	txs, total := state.GetAccountTXs(acc, 0, 10)
+	if total != 3 || len(txs) != 3 {
+		t.Fatalf("account should have 3 TXs, not %d", total)
+	}
+
+	expected := []AccountTx{
+		{Type: AccountTxReward, BlockNumber: 1, Value: state.genesis.BlockReward + 2},
+		{Type: AccountTxSent, BlockNumber: 1, Account: "caesar", Value: 20, Fee: 2},
+		{Type: AccountTxSent, BlockNumber: 0, Account: "babayaga", Value: 10, Fee: 1},
+	}
+	for i, tx := range txs {
+		e := expected[i]
+		if tx.Type != e.Type || tx.BlockNumber != e.BlockNumber || tx.Account != e.Account || tx.Value != e.Value || tx.Fee != e.Fee {
+			t.Fatalf("TX %d should be %+v, not %+v", i, e, tx)
+		}
+	}
+
+	page, total := state.GetAccountTXs("babayaga", 1, 1)
+	if total != 2 || len(page) != 1 || page[0].Type != AccountTxReceived || page[0].Account != acc {
+		t.Fatalf("second babayaga TX should be the one received from %s, got %+v", acc, page)
+	}
+
+	if page, total := state.GetAccountTXs("babayaga", 2, 1); total != 2 || len(page) != 0 {
+		t.Fatal("a page after the last TX should be empty")
+	}
+}
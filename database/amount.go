@@ -0,0 +1,107 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AmountDecimals is the number of TBB decimals an Amount holds
+const AmountDecimals = 8
+
+// TBB is the Amount of one TBB
+const TBB Amount = 100000000
+
+var errAmountOverflow = errors.New("amount overflows 64 bits")
+
+// Amount is a quantity of TBB counted in its smallest unit, 10^-8 TBB.
+// JSON encodes it as a decimal TBB string, and decodes it from either
+// a decimal TBB string or a JSON number of TBB.
+type Amount uint64
+
+// AmountFromTBB converts whole TBB into an Amount
+func AmountFromTBB(tbb uint64) (Amount, error) {
+	if tbb > uint64(^Amount(0)/TBB) {
+		return 0, errAmountOverflow
+	}
+
+	return Amount(tbb) * TBB, nil
+}
+
+// ParseAmount parses a decimal TBB amount of at most AmountDecimals decimals, like "12.5"
+func ParseAmount(s string) (Amount, error) {
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+
+	if whole == "" || len(fraction) > AmountDecimals {
+		return 0, fmt.Errorf("invalid amount '%s', must be TBB with at most %d decimals", s, AmountDecimals)
+	}
+
+	tbb, err := strconv.ParseUint(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount '%s', must be TBB with at most %d decimals", s, AmountDecimals)
+	}
+
+	units := uint64(0)
+	if fraction != "" {
+		units, err = strconv.ParseUint(fraction+strings.Repeat("0", AmountDecimals-len(fraction)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount '%s', must be TBB with at most %d decimals", s, AmountDecimals)
+		}
+	}
+
+	amount, err := AmountFromTBB(tbb)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount '%s': %w", s, err)
+	}
+
+	return amount.Add(Amount(units))
+}
+
+// Add returns the sum, or an error if it overflows
+func (a Amount) Add(b Amount) (Amount, error) {
+	if a > ^Amount(0)-b {
+		return 0, errAmountOverflow
+	}
+
+	return a + b, nil
+}
+
+// String formats the amount in TBB, without trailing zero decimals
+func (a Amount) String() string {
+	whole := strconv.FormatUint(uint64(a/TBB), 10)
+
+	fraction := strconv.FormatUint(uint64(a%TBB), 10)
+	fraction = strings.TrimRight(strings.Repeat("0", AmountDecimals-len(fraction))+fraction, "0")
+	if fraction == "" {
+		return whole
+	}
+
+	return whole + "." + fraction
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(a.String())), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	amount, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = amount
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	valid := map[string]Amount{
+		"0":            0,
+		"12":           12 * TBB,
+		"12.5":         12*TBB + TBB/2,
+		"0.00000001":   1,
+		"1.10000000":   TBB + TBB/10,
+		"184467440737": 184467440737 * TBB,
+	}
+	for s, expected := range valid {
+		amount, err := ParseAmount(s)
+		if err != nil || amount != expected {
+			t.Fatalf("'%s' should be parsed as %d, got %d %v", s, expected, amount, err)
+		}
+	}
+
+	for _, s := range []string{"", ".5", "-1", "+1", "1.000000001", "1e3", "1.-5", "184467440738"} {
+		if _, err := ParseAmount(s); err == nil {
+			t.Fatalf("'%s' should not be parsed", s)
+		}
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	expected := map[Amount]string{0: "0", 12 * TBB: "12", 12*TBB + TBB/2: "12.5", 1: "0.00000001"}
+	for amount, s := range expected {
+		if amount.String() != s {
+			t.Fatalf("%d should be formatted as '%s', got '%s'", uint64(amount), s, amount)
+		}
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	var amounts []Amount
+	if err := json.Unmarshal([]byte(`[1000, 0.5, "2.25"]`), &amounts); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(amounts) != 3 || amounts[0] != 1000*TBB || amounts[1] != TBB/2 || amounts[2] != 2*TBB+TBB/4 {
+		t.Fatalf("JSON numbers and strings should be decoded as TBB, got %v", amounts)
+	}
+
+	amountsJSON, err := json.Marshal(amounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(amountsJSON) != `["1000","0.5","2.25"]` {
+		t.Fatalf("amounts should be encoded as decimal TBB strings, got %s", amountsJSON)
+	}
+}
+
+func TestAmountAddOverflows(t *testing.T) {
+	if _, err := (^Amount(0)).Add(1); err == nil {
+		t.Fatal("overflowing sum should be rejected")
+	}
+
+	if _, err := AmountFromTBB(uint64(^Amount(0)/TBB) + 1); err == nil {
+		t.Fatal("overflowing TBB conversion should be rejected")
+	}
+}
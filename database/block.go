@@ -4,12 +4,9 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 )
 
-const BlockReward = 100
-
 type Hash [32]byte
 
 func (h Hash) MarshalText() ([]byte, error) {
@@ -17,6 +14,10 @@ func (h Hash) MarshalText() ([]byte, error) {
 }
 
 func (h *Hash) UnmarshalText(data []byte) error {
+	if len(data) != hex.EncodedLen(len(h)) {
+		return fmt.Errorf("invalid hash '%s', must be %d hex characters", data, hex.EncodedLen(len(h)))
+	}
+
 	_, err := hex.Decode(h[:], data)
 	return err
 }
@@ -37,13 +38,25 @@ type BlockHeader struct {
 	Nonce  uint32 `json:"nonce"`
 	Time   uint64 `json:"time"`
 
+	// number of leading zero bits the block hash must have
+	Difficulty uint32 `json:"difficulty"`
+
+	// Merkle root of the block TXs, the header hash commits to the TXs through it
+	TxRoot Hash `json:"tx_root"`
+
+	// sparse Merkle root of all the balances after the block is applied
+	StateRoot Hash `json:"state_root"`
+
 	// new attribute -> who mined this block and gets reward
 	Miner Account `json:"miner"`
+
+	// the older encoding version of a decoded block, 0 for the current one
+	encodingVersion byte
 }
 
 type Block struct {
 	Header BlockHeader `json:"header"`
-	TXs    []Tx        `json:"payload"`
+	TXs    []SignedTx  `json:"payload"`
 }
 
 type BlockFS struct {
@@ -51,22 +64,46 @@ type BlockFS struct {
 	Value Block `json:"block"`
 }
 
-func NewBlock(parent Hash, number uint64, nonce uint32, time uint64, miner Account, txs []Tx) Block {
-	return Block{BlockHeader{parent, number, nonce, time, miner}, txs}
+func NewBlock(parent Hash, number uint64, nonce uint32, time uint64, difficulty uint32, stateRoot Hash, miner Account, txs []SignedTx) (Block, error) {
+	txRoot, err := TxRoot(txs)
+	if err != nil {
+		return Block{}, err
+	}
+
+	header := BlockHeader{
+		Parent:     parent,
+		Number:     number,
+		Nonce:      nonce,
+		Time:       time,
+		Difficulty: difficulty,
+		TxRoot:     txRoot,
+		StateRoot:  stateRoot,
+		Miner:      miner,
+	}
+
+	return Block{header, txs}, nil
 }
 
+// Hash of the block is the hash of its header binary encoding only,
+// the TXs are committed to by the header TxRoot
 func (b Block) Hash() (Hash, error) {
-	blockJson, err := json.Marshal(b)
+	headerBin, err := b.Header.MarshalBinary()
 	if err != nil {
 		return Hash{}, err
 	}
 
-	return sha256.Sum256(blockJson), nil
+	return sha256.Sum256(headerBin), nil
 }
 
-func IsBlockHashValid(hash Hash) bool {
-	return fmt.Sprintf("%x", hash[0]) == "0" &&
-		fmt.Sprintf("%x", hash[1]) == "0" &&
-		fmt.Sprintf("%x", hash[2]) == "0" &&
-		fmt.Sprintf("%x", hash[3]) != "0"
+// FeesReward sums the fees of all the block TXs, credited to the block miner
+func (b Block) FeesReward() (Amount, error) {
+	var fees Amount
+	for _, tx := range b.TXs {
+		var err error
+		if fees, err = fees.Add(tx.Fee); err != nil {
+			return 0, err
+		}
+	}
+
+	return fees, nil
 }
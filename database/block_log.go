@@ -0,0 +1,169 @@
+package database
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// blockLog persists one framed record per main chain block, record N being
+// the one of block number N. Every record payload starts with the block
+// number and hash, as encoded by newBlockLogEncoder. Without a file the
+// payloads are kept in memory.
+type blockLog struct {
+	name    string
+	file    *os.File
+	offsets []int64
+	hashes  []Hash
+	size    int64
+
+	payloads [][]byte
+}
+
+func newMemoryBlockLog() *blockLog {
+	return &blockLog{name: "memory log"}
+}
+
+// openBlockLog loads the records hashes and offsets. The log is cut
+// at its first unreadable record, the missing records being written again
+// as the blocks are replayed.
+func openBlockLog(path string) (*blockLog, error) {
+	name := filepath.Base(path)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening %s file: %w", name, err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &blockLog{name: name, file: f}
+	reader := bufio.NewReader(io.NewSectionReader(f, 0, info.Size()))
+
+	for l.size < info.Size() {
+		payload, size, err := readRecordFrame(reader)
+
+		var number uint64
+		var hash Hash
+		if err == nil {
+			d := newDecoder(payload)
+			number, hash = d.uint64(), d.hash()
+			err = d.err
+		}
+
+		if err != nil || number != l.len() {
+			fmt.Printf("WARNING: %s has an unreadable record at offset %d, removing its last %d bytes\n", name, l.size, info.Size()-l.size)
+			if err := f.Truncate(l.size); err != nil {
+				return nil, fmt.Errorf("error while repairing %s: %w", name, err)
+			}
+			break
+		}
+
+		l.offsets = append(l.offsets, l.size)
+		l.hashes = append(l.hashes, hash)
+		l.size += size
+	}
+
+	return l, nil
+}
+
+// newBlockLogEncoder starts the encoding of a record payload
+func newBlockLogEncoder(number uint64, hash Hash) *encoder {
+	enc := newEncoder()
+	enc.uint64(number)
+	enc.hash(hash)
+
+	return enc
+}
+
+func (l *blockLog) len() uint64 {
+	return uint64(len(l.hashes))
+}
+
+func (l *blockLog) hashAt(number uint64) (Hash, bool) {
+	if number >= l.len() {
+		return Hash{}, false
+	}
+
+	return l.hashes[number], true
+}
+
+// append adds the record of the block following the latest logged one
+func (l *blockLog) append(number uint64, hash Hash, payload []byte) error {
+	if number != l.len() {
+		return fmt.Errorf("%s record of block '%d' must follow the one of block '%d'", l.name, number, int64(l.len())-1)
+	}
+
+	if l.file == nil {
+		l.payloads = append(l.payloads, payload)
+		l.hashes = append(l.hashes, hash)
+		return nil
+	}
+
+	record := encodeRecordFrame(payload)
+	if _, err := l.file.WriteAt(record, l.size); err != nil {
+		_ = l.file.Truncate(l.size)
+		return fmt.Errorf("error while writing %s: %w", l.name, err)
+	}
+
+	l.offsets = append(l.offsets, l.size)
+	l.hashes = append(l.hashes, hash)
+	l.size += int64(len(record))
+
+	return nil
+}
+
+// truncate removes the records of blocks from the number on
+func (l *blockLog) truncate(from uint64) error {
+	if from >= l.len() {
+		return nil
+	}
+
+	l.hashes = l.hashes[:from]
+
+	if l.file == nil {
+		l.payloads = l.payloads[:from]
+		return nil
+	}
+
+	l.size = l.offsets[from]
+	l.offsets = l.offsets[:from]
+
+	if err := l.file.Truncate(l.size); err != nil {
+		return fmt.Errorf("error while truncating %s: %w", l.name, err)
+	}
+
+	return nil
+}
+
+func (l *blockLog) read(number uint64) ([]byte, error) {
+	if number >= l.len() {
+		return nil, fmt.Errorf("block '%d' is not in %s", number, l.name)
+	}
+
+	if l.file == nil {
+		return l.payloads[number], nil
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(l.file, l.offsets[number], math.MaxInt64))
+	payload, _, err := readRecordFrame(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading %s record of block '%d': %w", l.name, number, err)
+	}
+
+	return payload, nil
+}
+
+func (l *blockLog) close() error {
+	if l.file == nil {
+		return nil
+	}
+
+	return l.file.Close()
+}
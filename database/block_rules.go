@@ -0,0 +1,80 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// checkBlockLimits checks the block rules not depending on the chain before it
+func checkBlockLimits(b Block, gen Genesis) error {
+	if uint64(len(b.TXs)) > gen.MaxBlockTXs {
+		return fmt.Errorf("block has %d TXs, more than the maximum %d", len(b.TXs), gen.MaxBlockTXs)
+	}
+
+	blockBin, err := b.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if uint64(len(blockBin)) > gen.MaxBlockSize {
+		return fmt.Errorf("block is %d bytes, more than the maximum %d bytes", len(blockBin), gen.MaxBlockSize)
+	}
+
+	if maxTime := uint64(time.Now().Unix()) + gen.MaxFutureBlockTime; b.Header.Time > maxTime {
+		return fmt.Errorf("block time '%d' is more than %d seconds in the future", b.Header.Time, gen.MaxFutureBlockTime)
+	}
+
+	return nil
+}
+
+// MedianTimePast is the median time of the latest blocks, which the next block must be later than
+func (s *State) MedianTimePast() uint64 {
+	if len(s.recentBlockTimes) == 0 {
+		return 0
+	}
+
+	times := make([]uint64, len(s.recentBlockTimes))
+	copy(times, s.recentBlockTimes)
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	return times[len(times)/2]
+}
+
+// NextBlockMinTime is the earliest time the next block can have
+func (s *State) NextBlockMinTime() uint64 {
+	if !s.hasGenesisBlock {
+		if s.genesis.Time.IsZero() {
+			return 0
+		}
+
+		return uint64(s.genesis.Time.Unix())
+	}
+
+	return s.MedianTimePast() + 1
+}
+
+// pushBlockTime records the time of the new latest block, forgetting the ones
+// too old to count in the median time
+func (s *State) pushBlockTime(blockTime uint64) {
+	times := append(s.recentBlockTimes, blockTime)
+	if n := int(s.genesis.MedianTimeBlocks); len(times) > n {
+		times = times[len(times)-n:]
+	}
+
+	s.recentBlockTimes = times
+}
+
+// resetBlockTimes recomputes the latest block times from the main chain first blocks
+func (s *State) resetBlockTimes(chain []chainBlock) {
+	s.recentBlockTimes = nil
+
+	from := 0
+	if n := int(s.genesis.MedianTimeBlocks); len(chain) > n {
+		from = len(chain) - n
+	}
+
+	for _, cb := range chain[from:] {
+		s.recentBlockTimes = append(s.recentBlockTimes, cb.header.Time)
+	}
+}
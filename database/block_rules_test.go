@@ -0,0 +1,93 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func TestAddBlockEnforcesBlockRules(t *testing.T) {
+	privKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc := PubKeyToAccount(privKey.PubKey())
+	genesis := fmt.Sprintf(`{"chain_id": "test-chain", "difficulty": %d, "median_time_blocks": 3, "max_future_block_time": 60, "max_block_txs": 1, "balances": {"%s": 1000}}`, testDifficulty, acc)
+
+	state, _ := createTestState(t, genesis)
+	defer state.Close()
+
+	for i := 0; i < 3; i++ {
+		mineTestBlock(t, state, "andrej", nil)
+	}
+
+	stateRoot, err := state.NextStateRoot("andrej", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	medianTime := state.MedianTimePast()
+	if state.NextBlockMinTime() != medianTime+1 {
+		t.Fatalf("next block should be later than the median time '%d', got min time '%d'", medianTime, state.NextBlockMinTime())
+	}
+
+	old := sealTestBlock(t, state, medianTime, stateRoot, "andrej", nil)
+	if _, err := state.AddBlock(old); err == nil || !strings.Contains(err.Error(), "median time") {
+		t.Fatalf("block not later than the median time should be rejected, got %v", err)
+	}
+
+	future := sealTestBlock(t, state, uint64(time.Now().Unix())+3600, stateRoot, "andrej", nil)
+	if _, err := state.AddBlock(future); err == nil || !strings.Contains(err.Error(), "in the future") {
+		t.Fatalf("block too far in the future should be rejected, got %v", err)
+	}
+
+	txs := []SignedTx{
+		signTestTx(t, privKey, NewTx(acc, "babayaga", 100, 1, 1, "")),
+		signTestTx(t, privKey, NewTx(acc, "babayaga", 100, 1, 2, "")),
+	}
+	if stateRoot, err = state.NextStateRoot("andrej", txs); err != nil {
+		t.Fatal(err)
+	}
+
+	crowded := sealTestBlock(t, state, state.NextBlockMinTime(), stateRoot, "andrej", txs)
+	if _, err := state.AddBlock(crowded); err == nil || !strings.Contains(err.Error(), "more than the maximum 1") {
+		t.Fatalf("block with too many TXs should be rejected, got %v", err)
+	}
+
+	if state.LatestBlock().Header.Number != 2 {
+		t.Fatalf("invalid blocks should not be added, latest block is '%d'", state.LatestBlock().Header.Number)
+	}
+
+	mineTestBlock(t, state, "andrej", txs[:1])
+}
+
+func TestCheckBlockLimitsRejectsLargeBlock(t *testing.T) {
+	gen, err := ParseGenesis([]byte(`{"max_block_size": 200}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := NewSignedTx(NewTx("andrej", "babayaga", 1, 1, 1, strings.Repeat("x", 100)), make([]byte, 65))
+
+	b, err := NewBlock(Hash{}, 1, 0, uint64(time.Now().Unix()), 0, Hash{}, "andrej", []SignedTx{tx})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkBlockLimits(b, gen); err == nil {
+		t.Fatal("block larger than the maximum size should be rejected")
+	}
+
+	size := BlockSizeOverhead("andrej") + tx.EncodedSize()
+	if blockBin, _ := b.MarshalBinary(); len(blockBin) > size {
+		t.Fatalf("block of %d bytes should fit in the %d bytes the miner counts", len(blockBin), size)
+	}
+
+	b.TXs = nil
+	if err := checkBlockLimits(b, gen); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"fmt"
+)
+
+// BlockStore persists the main chain blocks in order, block number N being the Nth stored block
+type BlockStore interface {
+	// Append stores the block after the latest one
+	Append(hash Hash, b Block) error
+	// Iterate calls fn with the blocks numbered from to to, both included,
+	// in order and stops at the first error returned by fn
+	Iterate(from uint64, to uint64, fn func(hash Hash, b Block) error) error
+	GetByHash(hash Hash) (Block, error)
+	// Truncate removes the blocks numbered from and above
+	Truncate(from uint64) error
+	// Len is the number of stored blocks
+	Len() uint64
+	Close() error
+}
+
+type storedBlock struct {
+	hash  Hash
+	block Block
+}
+
+// MemoryBlockStore keeps the blocks in memory only, for tests and embedded use
+type MemoryBlockStore struct {
+	blocks  []storedBlock
+	numbers map[Hash]uint64
+}
+
+func NewMemoryBlockStore() *MemoryBlockStore {
+	return &MemoryBlockStore{numbers: make(map[Hash]uint64)}
+}
+
+func (m *MemoryBlockStore) Append(hash Hash, b Block) error {
+	m.numbers[hash] = uint64(len(m.blocks))
+	m.blocks = append(m.blocks, storedBlock{hash, b})
+
+	return nil
+}
+
+func (m *MemoryBlockStore) Iterate(from uint64, to uint64, fn func(hash Hash, b Block) error) error {
+	for number := from; number <= to && number < m.Len(); number++ {
+		if err := fn(m.blocks[number].hash, m.blocks[number].block); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryBlockStore) GetByHash(hash Hash) (Block, error) {
+	number, ok := m.numbers[hash]
+	if !ok {
+		return Block{}, fmt.Errorf("block '%s' not found", hash.Hex())
+	}
+
+	return m.blocks[number].block, nil
+}
+
+func (m *MemoryBlockStore) Truncate(from uint64) error {
+	for number := from; number < m.Len(); number++ {
+		delete(m.numbers, m.blocks[number].hash)
+	}
+
+	if from < m.Len() {
+		m.blocks = m.blocks[:from]
+	}
+
+	return nil
+}
+
+func (m *MemoryBlockStore) Len() uint64 {
+	return uint64(len(m.blocks))
+}
+
+func (m *MemoryBlockStore) Close() error {
+	return nil
+}
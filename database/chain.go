@@ -0,0 +1,499 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+)
+
+// chainBlock is what the State remembers of every block of the main chain
+type chainBlock struct {
+	hash      Hash
+	header    BlockHeader
+	totalWork *big.Int
+	// values overwritten by the block, to roll it back on a reorg
+	undo blockUndo
+}
+
+// maxSideBlockDepth is how far below the main chain tip side blocks are kept.
+// Deeper ones are pruned and refused, a branch forking further back is not followed.
+const maxSideBlockDepth = 1000
+
+// sideBlock is a valid looking block of a competing branch, kept in memory only
+type sideBlock struct {
+	block     Block
+	totalWork *big.Int
+}
+
+// undoValue is a balance or a nonce before a block
+type undoValue struct {
+	value   uint64
+	existed bool
+}
+
+type blockUndo struct {
+	balances          map[Account]undoValue
+	nonces            map[Account]undoValue
+	retargetStartTime uint64
+}
+
+// blockWork is the expected number of hashes needed to mine a block of the difficulty
+func blockWork(difficulty uint32) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(difficulty))
+}
+
+// newBlockUndo records the state of every account the block touches, before it's applied
+func newBlockUndo(b Block, s *State) blockUndo {
+	undo := blockUndo{
+		balances:          make(map[Account]undoValue),
+		nonces:            make(map[Account]undoValue),
+		retargetStartTime: s.retargetStartTime,
+	}
+
+	record := func(acc Account) {
+		if _, ok := undo.balances[acc]; ok {
+			return
+		}
+
+		balance, ok := s.Balances[acc]
+		undo.balances[acc] = undoValue{uint64(balance), ok}
+
+		nonce, ok := s.Account2Nonce[acc]
+		undo.nonces[acc] = undoValue{uint64(nonce), ok}
+	}
+
+	record(b.Header.Miner)
+	for _, tx := range b.TXs {
+		record(tx.From)
+		record(tx.To)
+	}
+
+	return undo
+}
+
+// isMissing tells if the block was loaded from a balances snapshot without undo record
+func (u blockUndo) isMissing() bool {
+	return u.balances == nil
+}
+
+func (u blockUndo) revert(s *State) {
+	for acc, prev := range u.balances {
+		if prev.existed {
+			s.Balances[acc] = Amount(prev.value)
+		} else {
+			delete(s.Balances, acc)
+		}
+	}
+
+	for acc, prev := range u.nonces {
+		if prev.existed {
+			s.Account2Nonce[acc] = uint(prev.value)
+		} else {
+			delete(s.Account2Nonce, acc)
+		}
+	}
+
+	s.retargetStartTime = u.retargetStartTime
+}
+
+// TotalWork is the cumulative work of the main chain, the heaviest known chain
+func (s *State) TotalWork() *big.Int {
+	if len(s.mainChain) == 0 {
+		return big.NewInt(0)
+	}
+
+	return new(big.Int).Set(s.mainChain[len(s.mainChain)-1].totalWork)
+}
+
+// MainChainHash returns the hash of the main chain block at the height
+func (s *State) MainChainHash(number uint64) (Hash, bool) {
+	if number >= uint64(len(s.mainChain)) {
+		return Hash{}, false
+	}
+
+	return s.mainChain[number].hash, true
+}
+
+func (s *State) IsMainChainBlock(hash Hash) bool {
+	_, ok := s.mainChainIndex[hash]
+	return ok
+}
+
+func (s *State) IsKnownBlock(hash Hash) bool {
+	_, isSideBlock := s.sideBlocks[hash]
+	return s.IsMainChainBlock(hash) || isSideBlock
+}
+
+// PopOrphanedTXs returns, once, the TXs of blocks removed from the main chain
+// by reorgs which the new main chain doesn't include
+func (s *State) PopOrphanedTXs() []SignedTx {
+	txs := s.orphanedTXs
+	s.orphanedTXs = nil
+
+	return txs
+}
+
+// pushMainChainBlock records an applied and persisted block as the new chain tip
+func (s *State) pushMainChainBlock(b Block, hash Hash, undo blockUndo) {
+	totalWork := new(big.Int).Add(s.TotalWork(), blockWork(b.Header.Difficulty))
+
+	s.mainChain = append(s.mainChain, chainBlock{hash, b.Header, totalWork, undo})
+	s.mainChainIndex[hash] = b.Header.Number
+
+	s.latestBlock = b
+	s.latestBlockHash = hash
+	s.hasGenesisBlock = true
+}
+
+// parentOf returns the total work and height of the block's parent,
+// an empty parent hash being the root before the first block
+func (s *State) parentOf(b Block) (*big.Int, uint64, error) {
+	parent := b.Header.Parent
+
+	if parent.IsEmpty() {
+		return big.NewInt(0), 0, nil
+	}
+
+	if number, ok := s.mainChainIndex[parent]; ok {
+		return s.mainChain[number].totalWork, number + 1, nil
+	}
+
+	if sb, ok := s.sideBlocks[parent]; ok {
+		return sb.totalWork, sb.block.Header.Number + 1, nil
+	}
+
+	return nil, 0, fmt.Errorf("parent '%x' of block '%d' is unknown", parent, b.Header.Number)
+}
+
+// parentView returns a State only knowing what the block's header rules need
+// of its branch: the parent block, its retarget start time and latest block times
+func (s *State) parentView(b Block) State {
+	view := State{genesis: s.genesis}
+
+	// the branch headers ending with the parent, newest first
+	headers := make([]BlockHeader, 0, s.genesis.MedianTimeBlocks)
+	hasRetargetStart := false
+
+	for hash := b.Header.Parent; !hash.IsEmpty(); {
+		var header BlockHeader
+		if number, ok := s.mainChainIndex[hash]; ok {
+			header = s.mainChain[number].header
+		} else if sb, ok := s.sideBlocks[hash]; ok {
+			header = sb.block.Header
+		} else {
+			break
+		}
+
+		if uint64(len(headers)) < s.genesis.MedianTimeBlocks {
+			headers = append(headers, header)
+		}
+
+		if !hasRetargetStart && header.Number%s.genesis.RetargetInterval == 0 {
+			view.retargetStartTime = header.Time
+			hasRetargetStart = true
+		}
+
+		if hasRetargetStart && uint64(len(headers)) == s.genesis.MedianTimeBlocks {
+			break
+		}
+		hash = header.Parent
+	}
+
+	if len(headers) == 0 {
+		return view
+	}
+
+	view.hasGenesisBlock = true
+	view.latestBlock = Block{Header: headers[0]}
+	for i := len(headers) - 1; i >= 0; i-- {
+		view.recentBlockTimes = append(view.recentBlockTimes, headers[i].Time)
+	}
+
+	return view
+}
+
+// pruneSideBlocks drops the side blocks too deep below the tip, then the ones
+// left without a known parent, so every side block still leads to the main chain
+func (s *State) pruneSideBlocks() {
+	tip := s.latestBlock.Header.Number
+	if tip < maxSideBlockDepth || len(s.sideBlocks) == 0 {
+		return
+	}
+
+	hashes := make([]Hash, 0, len(s.sideBlocks))
+	for hash := range s.sideBlocks {
+		hashes = append(hashes, hash)
+	}
+	// parents before their children
+	sort.Slice(hashes, func(i, j int) bool {
+		return s.sideBlocks[hashes[i]].block.Header.Number < s.sideBlocks[hashes[j]].block.Header.Number
+	})
+
+	for _, hash := range hashes {
+		header := s.sideBlocks[hash].block.Header
+		_, hasMainParent := s.mainChainIndex[header.Parent]
+		_, hasSideParent := s.sideBlocks[header.Parent]
+
+		if header.Number+maxSideBlockDepth < tip || !(hasMainParent || hasSideParent || header.Parent.IsEmpty()) {
+			delete(s.sideBlocks, hash)
+		}
+	}
+}
+
+// addSideBlock stores a block not extending the main chain tip and
+// reorganises the chain if the block's branch has more cumulative work.
+// The branch blocks are fully validated only once the branch gets heavier.
+func (s *State) addSideBlock(b Block, hash Hash) error {
+	parentWork, expectedNumber, err := s.parentOf(b)
+	if err != nil {
+		return err
+	}
+
+	if b.Header.Number != expectedNumber {
+		return fmt.Errorf("next expected block must be '%d' not '%d'", expectedNumber, b.Header.Number)
+	}
+
+	if tip := s.latestBlock.Header.Number; b.Header.Number+maxSideBlockDepth < tip {
+		return fmt.Errorf("block '%d' is more than %d blocks below the tip '%d'", b.Header.Number, maxSideBlockDepth, tip)
+	}
+
+	parent := s.parentView(b)
+
+	if expectedDifficulty := parent.NextBlockDifficulty(); b.Header.Difficulty != expectedDifficulty {
+		return fmt.Errorf("next block difficulty must be '%d' not '%d'", expectedDifficulty, b.Header.Difficulty)
+	}
+
+	if parent.hasGenesisBlock && b.Header.Time <= parent.MedianTimePast() {
+		return fmt.Errorf("block time '%d' must be later than the median time '%d' of the latest blocks", b.Header.Time, parent.MedianTimePast())
+	}
+
+	txRoot, err := TxRoot(b.TXs)
+	if err != nil {
+		return err
+	}
+
+	if b.Header.TxRoot != txRoot {
+		return fmt.Errorf("block TX root must be '%x' not '%x'", txRoot, b.Header.TxRoot)
+	}
+
+	if !IsBlockHashValid(hash, b.Header.Difficulty) {
+		return fmt.Errorf("invalid block %x", hash)
+	}
+
+	if err := checkBlockLimits(b, s.genesis); err != nil {
+		return err
+	}
+
+	totalWork := new(big.Int).Add(parentWork, blockWork(b.Header.Difficulty))
+	s.sideBlocks[hash] = sideBlock{b, totalWork}
+
+	if totalWork.Cmp(s.TotalWork()) <= 0 {
+		fmt.Printf("Stored side branch block '%x' at height %d\n", hash, b.Header.Number)
+		return nil
+	}
+
+	return s.reorg(hash)
+}
+
+// reorg switches the main chain to the branch ending with the tip side block.
+// Balances are rolled back to the common ancestor and the branch applied on
+// a copy first, so an invalid branch leaves the State untouched.
+// block.db is truncated after the ancestor and the branch appended,
+// at any point it holds a valid chain.
+func (s *State) reorg(tip Hash) error {
+	branch := make([]Block, 0)
+	branchHashes := make([]Hash, 0)
+
+	keep := 0
+	for hash := tip; ; {
+		if hash.IsEmpty() {
+			break
+		}
+
+		if number, ok := s.mainChainIndex[hash]; ok {
+			keep = int(number) + 1
+			break
+		}
+
+		sb := s.sideBlocks[hash]
+		branch = append([]Block{sb.block}, branch...)
+		branchHashes = append([]Hash{hash}, branchHashes...)
+		hash = sb.block.Header.Parent
+	}
+
+	fmt.Printf("Reorganising chain: %d blocks replaced by %d blocks of a heavier branch\n", len(s.mainChain)-keep, len(branch))
+
+	if err := s.loadMissingUndos(keep); err != nil {
+		return err
+	}
+
+	pendingState := s.copy()
+	for i := len(s.mainChain) - 1; i >= keep; i-- {
+		s.mainChain[i].undo.revert(&pendingState)
+	}
+
+	pendingState.hasGenesisBlock = keep > 0
+	pendingState.resetBlockTimes(s.mainChain[:keep])
+	pendingState.latestBlock = Block{}
+	pendingState.latestBlockHash = Hash{}
+	if keep > 0 {
+		pendingState.latestBlock = Block{Header: s.mainChain[keep-1].header}
+		pendingState.latestBlockHash = s.mainChain[keep-1].hash
+	}
+
+	undos := make([]blockUndo, len(branch))
+	entries := make([]journalEntry, len(branch))
+	for i, b := range branch {
+		undos[i] = newBlockUndo(b, &pendingState)
+
+		if err := applyBlock(b, &pendingState); err != nil {
+			// the block and its descendants can never become valid
+			for _, invalid := range branchHashes[i:] {
+				delete(s.sideBlocks, invalid)
+			}
+
+			return fmt.Errorf("reorg to block '%x' failed: %w", tip, err)
+		}
+
+		entries[i] = newJournalEntry(b, branchHashes[i], undos[i], &pendingState)
+
+		pendingState.latestBlock = b
+		pendingState.latestBlockHash = branchHashes[i]
+		pendingState.hasGenesisBlock = true
+	}
+
+	removed, err := s.replaceMainChainTail(keep, branch, branchHashes)
+	if err != nil {
+		return err
+	}
+
+	for i := len(s.mainChain) - 1; i >= keep; i-- {
+		delete(s.mainChainIndex, s.mainChain[i].hash)
+		s.sideBlocks[s.mainChain[i].hash] = sideBlock{removed[i-keep], s.mainChain[i].totalWork}
+	}
+	s.mainChain = s.mainChain[:keep]
+
+	if err := s.journal.truncate(uint64(keep)); err != nil {
+		fmt.Printf("WARNING: %s\n", err)
+	}
+
+	if err := s.indexes.truncate(uint64(keep)); err != nil {
+		fmt.Printf("WARNING: %s\n", err)
+	}
+
+	s.Balances = pendingState.Balances
+	s.Account2Nonce = pendingState.Account2Nonce
+	s.retargetStartTime = pendingState.retargetStartTime
+	s.recentBlockTimes = pendingState.recentBlockTimes
+
+	includedTXs := make(map[Hash]struct{})
+	for i, b := range branch {
+		s.pushMainChainBlock(b, branchHashes[i], undos[i])
+		s.journalBlock(entries[i])
+		s.indexBlock(b, branchHashes[i])
+		delete(s.sideBlocks, branchHashes[i])
+
+		for _, tx := range b.TXs {
+			txHash, _ := tx.Hash()
+			includedTXs[txHash] = struct{}{}
+		}
+	}
+
+	for _, b := range removed {
+		for _, tx := range b.TXs {
+			txHash, _ := tx.Hash()
+			if _, ok := includedTXs[txHash]; !ok {
+				s.orphanedTXs = append(s.orphanedTXs, tx)
+			}
+		}
+	}
+	s.pruneSideBlocks()
+
+	return nil
+}
+
+// loadMissingUndos reads from the journal the undo records of the main chain
+// blocks from the number on the balances snapshot covered. If the journal
+// can't provide them the whole chain is replayed.
+func (s *State) loadMissingUndos(from int) error {
+	if from >= len(s.mainChain) || !s.mainChain[from].undo.isMissing() {
+		return nil
+	}
+
+	isJournaled := true
+	for i := from; i < len(s.mainChain) && s.mainChain[i].undo.isMissing(); i++ {
+		entry, err := s.journal.read(uint64(i))
+		if err != nil || entry.hash != s.mainChain[i].hash {
+			isJournaled = false
+			break
+		}
+
+		s.mainChain[i].undo = entry.undo()
+	}
+
+	if isJournaled {
+		return nil
+	}
+
+	fmt.Println("Replaying the chain to roll back blocks covered by the balances snapshot....")
+
+	replayed, err := newState(s.genesis, s.store, newMemoryBalanceJournal(), newMemoryChainIndexes(s.genesis), "")
+	if err != nil {
+		return err
+	}
+
+	for i := from; i < len(s.mainChain); i++ {
+		if s.mainChain[i].undo.isMissing() {
+			s.mainChain[i].undo = replayed.mainChain[i].undo
+		}
+	}
+
+	return nil
+}
+
+// replaceMainChainTail replaces the stored blocks after the first keep ones with the branch
+// and returns the replaced blocks. If the branch can't be stored the replaced blocks are restored.
+func (s *State) replaceMainChainTail(keep int, branch []Block, branchHashes []Hash) ([]Block, error) {
+	removed := make([]Block, 0)
+	err := s.store.Iterate(uint64(keep), math.MaxUint64, func(_ Hash, b Block) error {
+		removed = append(removed, b)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error while reading replaced blocks: %w", err)
+	}
+
+	if err := s.store.Truncate(uint64(keep)); err != nil {
+		return nil, err
+	}
+
+	for i, b := range branch {
+		err := s.store.Append(branchHashes[i], b)
+		if err == nil {
+			continue
+		}
+
+		if restoreErr := s.restoreMainChainTail(keep, removed); restoreErr != nil {
+			return nil, fmt.Errorf("error while storing branch: %s, restoring replaced blocks failed: %w", err, restoreErr)
+		}
+
+		return nil, fmt.Errorf("error while storing branch: %w", err)
+	}
+
+	return removed, nil
+}
+
+func (s *State) restoreMainChainTail(keep int, removed []Block) error {
+	if err := s.store.Truncate(uint64(keep)); err != nil {
+		return err
+	}
+
+	for i, b := range removed {
+		if err := s.store.Append(s.mainChain[keep+i].hash, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
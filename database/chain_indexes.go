@@ -0,0 +1,91 @@
+package database
+
+import (
+	"fmt"
+)
+
+// chainIndex is a lookup index of the main chain blocks content,
+// persisted as one entry per block and rebuilt by replaying the blocks
+type chainIndex interface {
+	hashAt(number uint64) (Hash, bool)
+	append(b Block, hash Hash) error
+	truncate(from uint64) error
+	close() error
+}
+
+type chainIndexes struct {
+	tx      *txIndex
+	account *accountIndex
+}
+
+func newMemoryChainIndexes(gen Genesis) chainIndexes {
+	return chainIndexes{newMemoryTxIndex(), newMemoryAccountIndex(gen)}
+}
+
+func openChainIndexes(dataDir string, gen Genesis) (chainIndexes, error) {
+	tx, err := openTxIndex(getTxIndexFilePath(dataDir))
+	if err != nil {
+		return chainIndexes{}, err
+	}
+
+	account, err := openAccountIndex(getAccountIndexFilePath(dataDir), gen)
+	if err != nil {
+		return chainIndexes{}, err
+	}
+
+	return chainIndexes{tx, account}, nil
+}
+
+func (c chainIndexes) all() []chainIndex {
+	return []chainIndex{c.tx, c.account}
+}
+
+// reconcile indexes the stored block in the indexes missing it at its height
+func (c chainIndexes) reconcile(b Block, hash Hash) error {
+	for _, idx := range c.all() {
+		if indexed, _ := idx.hashAt(b.Header.Number); indexed == hash {
+			continue
+		}
+
+		if err := idx.truncate(b.Header.Number); err != nil {
+			return err
+		}
+
+		if err := idx.append(b, hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// truncate removes the entries of blocks from the number on
+func (c chainIndexes) truncate(from uint64) error {
+	for _, idx := range c.all() {
+		if err := idx.truncate(from); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c chainIndexes) close() error {
+	for _, idx := range c.all() {
+		if err := idx.close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexBlock records the content of the new main chain block.
+// The indexes can be rebuilt by replaying the blocks, so a failed write is only reported.
+func (s *State) indexBlock(b Block, hash Hash) {
+	for _, idx := range s.indexes.all() {
+		if err := idx.append(b, hash); err != nil {
+			fmt.Printf("WARNING: %s, block '%d' and the next ones can't be looked up until restart\n", err, b.Header.Number)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package database
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+const testDifficulty = 4
+
+func TestAddBlockReorganisesToHeavierBranch(t *testing.T) {
+	privKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc := PubKeyToAccount(privKey.PubKey())
+	genesis := fmt.Sprintf(`{"chain_id": "test-chain", "difficulty": %d, "balances": {"%s": 1000}}`, testDifficulty, acc)
+
+	state, dataDir := createTestState(t, genesis)
+	defer state.Close()
+	gen, err := ParseGenesis([]byte(genesis))
+	if err != nil {
+		t.Fatal(err)
+	}
+	branchState, err := NewStateFromStore(gen, NewMemoryBlockStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := NewTx(acc, "babayaga", 100, 1, 1, "")
+	txHash, err := tx.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := ecdsa.SignCompact(privKey, txHash[:], false)
+
+	mineTestBlock(t, state, "andrej", nil)
+	mineTestBlock(t, state, "andrej", []SignedTx{NewSignedTx(tx, sig)})
+
+	branch := []Block{
+		mineTestBlock(t, branchState, "caesar", nil),
+		mineTestBlock(t, branchState, "caesar", nil),
+		mineTestBlock(t, branchState, "caesar", nil),
+	}
+
+	for i, b := range branch {
+		if _, err := state.AddBlock(b); err != nil {
+			t.Fatal(err)
+		}
+
+		// a lighter or equally heavy branch doesn't replace the main chain
+		if i < 2 && state.Balances["babayaga"] != 100 {
+			t.Fatalf("main chain should not be replaced by %d branch blocks", i+1)
+		}
+	}
+
+	if state.LatestBlockHash() != branchState.LatestBlockHash() {
+		t.Fatal("main chain should be replaced by the heavier branch")
+	}
+
+	if state.StateRoot() != branchState.StateRoot() {
+		t.Fatal("balances should be the heavier branch ones")
+	}
+
+	if _, ok := state.GetTxLocation(txHash); ok {
+		t.Fatal("TX of the replaced blocks should not be indexed")
+	}
+
+	orphaned := state.PopOrphanedTXs()
+	if len(orphaned) != 1 || orphaned[0].Nonce != 1 {
+		t.Fatalf("the TX missing from the heavier branch should be orphaned, got %v", orphaned)
+	}
+
+	reloaded, err := NewStateFromDisk(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.Close()
+
+	if reloaded.LatestBlockHash() != branchState.LatestBlockHash() || reloaded.StateRoot() != branchState.StateRoot() {
+		t.Fatal("block.db should hold the heavier branch")
+	}
+}
+
+func TestAddBlockChecksSideBlockDifficultyAndTime(t *testing.T) {
+	state, _ := createTestState(t, fmt.Sprintf(`{"chain_id": "test-chain", "difficulty": %d, "balances": {"andrej": 1000}}`, testDifficulty))
+	defer state.Close()
+
+	first := mineTestBlock(t, state, "andrej", nil)
+	mineTestBlock(t, state, "andrej", nil)
+	mineTestBlock(t, state, "andrej", nil)
+
+	firstHash, err := first.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sideBlock := func(difficulty uint32, blockTime uint64) Block {
+		b, err := NewBlock(firstHash, 1, 0, blockTime, difficulty, Hash{}, "caesar", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return findTestPoW(t, b)
+	}
+
+	blockTime := first.Header.Time + 1
+	tests := []struct {
+		name  string
+		block Block
+		err   string
+	}{
+		{"easier than its parent's retarget", sideBlock(testDifficulty-1, blockTime), "difficulty"},
+		{"not later than its parent's median time", sideBlock(testDifficulty, first.Header.Time), "median time"},
+	}
+
+	for _, tc := range tests {
+		_, err := state.AddBlock(tc.block)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("side block %s should be rejected, got %v", tc.name, err)
+		}
+
+		hash, _ := tc.block.Hash()
+		if state.IsKnownBlock(hash) {
+			t.Fatalf("side block %s should not be stored", tc.name)
+		}
+	}
+
+	valid := sideBlock(testDifficulty, blockTime)
+	if _, err := state.AddBlock(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	validHash, _ := valid.Hash()
+	if !state.IsKnownBlock(validHash) || state.IsMainChainBlock(validHash) {
+		t.Fatal("a valid lighter side block should be stored off the main chain")
+	}
+}
+
+func createTestState(t *testing.T, genesis string) (*State, string) {
+	dataDir, err := ioutil.TempDir("", "tbb_chain_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dataDir) })
+
+	if err := InitDataDirIfNotExists(dataDir, []byte(genesis)); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := NewStateFromDisk(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return state, dataDir
+}
+
+func mineTestBlock(t *testing.T, s *State, miner Account, txs []SignedTx) Block {
+	stateRoot, err := s.NextStateRoot(miner, txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockTime := uint64(time.Now().Unix())
+	if minTime := s.NextBlockMinTime(); blockTime < minTime {
+		blockTime = minTime
+	}
+
+	b := sealTestBlock(t, s, blockTime, stateRoot, miner, txs)
+	if _, err := s.AddBlock(b); err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+// sealTestBlock builds the next block of the State and finds its PoW without adding it
+func sealTestBlock(t *testing.T, s *State, blockTime uint64, stateRoot Hash, miner Account, txs []SignedTx) Block {
+	b, err := NewBlock(s.LatestBlockHash(), s.NextBlockNumber(), 0, blockTime, s.NextBlockDifficulty(), stateRoot, miner, txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return findTestPoW(t, b)
+}
+
+func findTestPoW(t *testing.T, b Block) Block {
+	for {
+		hash, err := b.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !hash.IsEmpty() && IsBlockHashValid(hash, b.Header.Difficulty) {
+			break
+		}
+		b.Header.Nonce++
+	}
+
+	return b
+}
+
+func signTestTx(t *testing.T, privKey *secp256k1.PrivateKey, tx Tx) SignedTx {
+	txHash, err := tx.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewSignedTx(tx, ecdsa.SignCompact(privKey, txHash[:], false))
+}
@@ -1,48 +1,60 @@
 package database
 
 import (
-	"bufio"
-	"encoding/json"
 	"fmt"
-	"os"
-	"reflect"
+	"math"
 )
 
-func GetBlocksAfter(blockHash Hash, dataDir string) ([]Block, error) {
-	f, err := os.OpenFile(getBlockDBFilePath(dataDir), os.O_RDONLY, 0600)
-	if err != nil {
-		return nil, fmt.Errorf("error while opening blocks.db file in GetBlocksAfer func: %w", err)
-	}
-
+// GetBlocksAfter returns the main chain blocks after the block,
+// all of them for an empty hash and none for an unknown block
+func (s *State) GetBlocksAfter(blockHash Hash) ([]Block, error) {
 	blocks := make([]Block, 0)
 
-	shouldStartCollecting := false
+	from := uint64(0)
+	if !blockHash.IsEmpty() {
+		number, ok := s.mainChainIndex[blockHash]
+		if !ok {
+			return blocks, nil
+		}
+		from = number + 1
+	}
 
-	if reflect.DeepEqual(blockHash, Hash{}) {
-		shouldStartCollecting = true
+	err := s.ForEachBlock(from, math.MaxUint64, func(_ Hash, b Block) error {
+		blocks = append(blocks, b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	scanner := bufio.NewScanner(f)
+	return blocks, nil
+}
 
-	for scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			return nil, fmt.Errorf("error while scanning block.db file in GetBlocksAfter func: %w", err)
-		}
+func (s *State) GetBlockByHash(blockHash Hash) (Block, error) {
+	return s.store.GetByHash(blockHash)
+}
 
-		var blocksFS BlockFS
-		err = json.Unmarshal(scanner.Bytes(), &blocksFS)
-		if err != nil {
-			return nil, fmt.Errorf("error while unmarshalling to BlockFS in GetBlockAfter func: %w", err)
-		}
+func (s *State) GetBlockByNumber(number uint64) (Block, error) {
+	var block Block
+	found := false
 
-		if shouldStartCollecting {
-			blocks = append(blocks, blocksFS.Value)
-		}
+	err := s.ForEachBlock(number, number, func(_ Hash, b Block) error {
+		block = b
+		found = true
+		return nil
+	})
+	if err != nil {
+		return Block{}, err
+	}
 
-		if blockHash == blocksFS.Key {
-			shouldStartCollecting = true
-		}
+	if !found {
+		return Block{}, fmt.Errorf("block number '%d' not found", number)
 	}
 
-	return blocks, nil
+	return block, nil
+}
+
+// ForEachBlock calls fn with the main chain blocks numbered from to to, both included
+func (s *State) ForEachBlock(from uint64, to uint64, fn func(hash Hash, b Block) error) error {
+	return s.store.Iterate(from, to, fn)
 }
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"math/bits"
+)
+
+const DefaultDifficulty = 24
+const DefaultTargetBlockTime = 30
+const DefaultRetargetInterval = 10
+
+// NextBlockDifficulty returns the number of leading zero bits
+// the hash of the next block must have.
+//
+// The difficulty starts at the genesis value and is retargeted every
+// RetargetInterval blocks from the time the last interval took to mine:
+// one bit harder if it was twice as fast as targeted, one bit easier
+// if it was twice as slow. A bit doubles/halves the expected work.
+func (s *State) NextBlockDifficulty() uint32 {
+	if !s.hasGenesisBlock {
+		return s.genesis.Difficulty
+	}
+
+	difficulty := s.latestBlock.Header.Difficulty
+	nextNumber := s.latestBlock.Header.Number + 1
+
+	if nextNumber%s.genesis.RetargetInterval != 0 {
+		return difficulty
+	}
+
+	expectedTimespan := (s.genesis.RetargetInterval - 1) * s.genesis.TargetBlockTime
+	actualTimespan := uint64(0)
+	if s.latestBlock.Header.Time > s.retargetStartTime {
+		actualTimespan = s.latestBlock.Header.Time - s.retargetStartTime
+	}
+
+	if actualTimespan < expectedTimespan/2 {
+		return difficulty + 1
+	}
+
+	if actualTimespan > expectedTimespan*2 && difficulty > 1 {
+		return difficulty - 1
+	}
+
+	return difficulty
+}
+
+// IsBlockHashValid checks the hash starts with at least difficulty zero bits
+func IsBlockHashValid(hash Hash, difficulty uint32) bool {
+	return leadingZeroBits(hash) >= difficulty
+}
+
+func leadingZeroBits(hash Hash) uint32 {
+	zeros := uint32(0)
+
+	for _, b := range hash {
+		zeros += uint32(bits.LeadingZeros8(b))
+		if b != 0 {
+			break
+		}
+	}
+
+	return zeros
+}
@@ -0,0 +1,471 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// EncodingVersion prefixes every binary encoded Tx, SignedTx, BlockHeader and Block.
+// Hashes are computed over the binary encoding, so any change to it must bump the version.
+// Version 2 counts amounts in 10^-8 TBB instead of whole TBB,
+// version 3 adds the multisig account and signatures to SignedTx,
+// version 4 adds the lock height and time to Tx.
+const EncodingVersion byte = 4
+
+// Every older version is still decoded, the fields it lacks set to zero.
+// A decoded Tx, SignedTx, BlockHeader or Block keeps its version and is
+// encoded with it again, so its hash and signatures don't change.
+const minEncodingVersion byte = 1
+
+// amountsEncodingVersion is the first version counting amounts in 10^-8 TBB
+const amountsEncodingVersion byte = 2
+
+const multisigEncodingVersion byte = 3
+const txLockEncodingVersion byte = 4
+
+var errShortEncoding = errors.New("binary encoding is too short")
+
+// encoder writes fixed size big-endian integers and uvarint length prefixed strings and bytes
+type encoder struct {
+	buf     bytes.Buffer
+	version byte
+}
+
+func newEncoder() *encoder {
+	return newVersionEncoder(EncodingVersion)
+}
+
+// newVersionEncoder encodes with the layout of an older version, for the values decoded from it
+func newVersionEncoder(version byte) *encoder {
+	e := &encoder{version: version}
+	e.buf.WriteByte(version)
+
+	return e
+}
+
+// encodingVersionOf returns the version a value encodes with, where 0 is the current one
+func encodingVersionOf(version byte) byte {
+	if version == 0 {
+		return EncodingVersion
+	}
+
+	return version
+}
+
+func (e *encoder) uint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	e.buf.Write(b[:])
+}
+
+func (e *encoder) uint64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	e.buf.Write(b[:])
+}
+
+// amount writes whole TBB before amountsEncodingVersion, only the multiples
+// of TBB a chain of such blocks holds
+func (e *encoder) amount(a Amount) {
+	if e.version < amountsEncodingVersion {
+		e.uint64(uint64(a / TBB))
+		return
+	}
+
+	e.uint64(uint64(a))
+}
+
+func (e *encoder) length(n int) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf.Write(b[:binary.PutUvarint(b[:], uint64(n))])
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.length(len(b))
+	e.buf.Write(b)
+}
+
+func (e *encoder) string(s string) {
+	e.bytes([]byte(s))
+}
+
+func (e *encoder) hash(h Hash) {
+	e.buf.Write(h[:])
+}
+
+// decoder reads what encoder writes, remembering the first error
+type decoder struct {
+	buf     []byte
+	err     error
+	version byte
+}
+
+func newDecoder(data []byte) *decoder {
+	d := &decoder{buf: data}
+
+	version := d.next(1)
+	if d.err == nil && (version[0] < minEncodingVersion || version[0] > EncodingVersion) {
+		d.err = fmt.Errorf("unsupported binary encoding version %d, this node reads versions %d to %d", version[0], minEncodingVersion, EncodingVersion)
+	}
+	d.version = version[0]
+
+	return d
+}
+
+// olderVersion returns the version a decoded value keeps, 0 for the current one
+func (d *decoder) olderVersion() byte {
+	if d.version == EncodingVersion {
+		return 0
+	}
+
+	return d.version
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return make([]byte, n)
+	}
+
+	if len(d.buf) < n {
+		d.err = errShortEncoding
+		return make([]byte, n)
+	}
+
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+
+	return b
+}
+
+func (d *decoder) uint32() uint32 {
+	return binary.BigEndian.Uint32(d.next(4))
+}
+
+func (d *decoder) uint64() uint64 {
+	return binary.BigEndian.Uint64(d.next(8))
+}
+
+func (d *decoder) amount() Amount {
+	v := d.uint64()
+	if d.version >= amountsEncodingVersion {
+		return Amount(v)
+	}
+
+	a, err := AmountFromTBB(v)
+	if err != nil && d.err == nil {
+		d.err = err
+	}
+
+	return a
+}
+
+func (d *decoder) length() int {
+	if d.err != nil {
+		return 0
+	}
+
+	n, size := binary.Uvarint(d.buf)
+	if size <= 0 || n > uint64(len(d.buf)-size) {
+		d.err = errShortEncoding
+		return 0
+	}
+	d.buf = d.buf[size:]
+
+	return int(n)
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.length()
+	if n == 0 {
+		return nil
+	}
+
+	return append([]byte(nil), d.next(n)...)
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) hash() Hash {
+	var h Hash
+	copy(h[:], d.next(len(h)))
+
+	return h
+}
+
+// finish returns the first decoding error, or an error if bytes are left over
+func (d *decoder) finish() error {
+	if d.err != nil {
+		return d.err
+	}
+
+	if len(d.buf) > 0 {
+		return fmt.Errorf("binary encoding has %d unexpected trailing bytes", len(d.buf))
+	}
+
+	return nil
+}
+
+func (t Tx) encode(e *encoder) {
+	e.string(string(t.From))
+	e.string(string(t.To))
+	e.amount(t.Value)
+	e.amount(t.Fee)
+	e.uint64(uint64(t.Nonce))
+	e.string(t.Data)
+	e.uint64(t.Time)
+
+	if e.version >= txLockEncodingVersion {
+		e.uint64(t.LockHeight)
+		e.uint64(t.LockTime)
+	}
+}
+
+func (t *Tx) decode(d *decoder) {
+	t.From = Account(d.string())
+	t.To = Account(d.string())
+	t.Value = d.amount()
+	t.Fee = d.amount()
+	t.Nonce = uint(d.uint64())
+	t.Data = d.string()
+	t.Time = d.uint64()
+
+	t.LockHeight, t.LockTime = 0, 0
+	if d.version >= txLockEncodingVersion {
+		t.LockHeight = d.uint64()
+		t.LockTime = d.uint64()
+	}
+	t.encodingVersion = d.olderVersion()
+}
+
+// a SignedTx without multisig account encodes an empty one of threshold 0
+func (t SignedTx) encode(e *encoder) {
+	t.Tx.encode(e)
+	e.bytes(t.Sig)
+
+	if e.version < multisigEncodingVersion {
+		return
+	}
+
+	multisig := Multisig{}
+	if t.Multisig != nil {
+		multisig = *t.Multisig
+	}
+	multisig.encode(e)
+
+	e.length(len(t.Sigs))
+	for _, sig := range t.Sigs {
+		e.bytes(sig)
+	}
+}
+
+func (t *SignedTx) decode(d *decoder) {
+	t.Tx.decode(d)
+	t.Sig = d.bytes()
+
+	t.Multisig, t.Sigs = nil, nil
+	if d.version < multisigEncodingVersion {
+		return
+	}
+
+	multisig := Multisig{}
+	multisig.decode(d)
+	if multisig.Threshold != 0 || len(multisig.PubKeys) != 0 {
+		t.Multisig = &multisig
+	}
+
+	if n := d.length(); n > 0 {
+		t.Sigs = make([][]byte, n)
+		for i := range t.Sigs {
+			t.Sigs[i] = d.bytes()
+		}
+	}
+}
+
+func (h BlockHeader) encode(e *encoder) {
+	e.hash(h.Parent)
+	e.uint64(h.Number)
+	e.uint32(h.Nonce)
+	e.uint64(h.Time)
+	e.uint32(h.Difficulty)
+	e.hash(h.TxRoot)
+	e.hash(h.StateRoot)
+	e.string(string(h.Miner))
+}
+
+func (h *BlockHeader) decode(d *decoder) {
+	h.Parent = d.hash()
+	h.Number = d.uint64()
+	h.Nonce = d.uint32()
+	h.Time = d.uint64()
+	h.Difficulty = d.uint32()
+	h.TxRoot = d.hash()
+	h.StateRoot = d.hash()
+	h.Miner = Account(d.string())
+	h.encodingVersion = d.olderVersion()
+}
+
+// EncodingVersion is the version the TX was decoded from, or the current one
+func (t Tx) EncodingVersion() byte {
+	return encodingVersionOf(t.encodingVersion)
+}
+
+func (t Tx) MarshalBinary() ([]byte, error) {
+	e := newVersionEncoder(t.EncodingVersion())
+	t.encode(e)
+
+	return e.buf.Bytes(), nil
+}
+
+func (t *Tx) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	t.decode(d)
+
+	return d.finish()
+}
+
+func (t SignedTx) MarshalBinary() ([]byte, error) {
+	e := newVersionEncoder(t.EncodingVersion())
+	t.encode(e)
+
+	return e.buf.Bytes(), nil
+}
+
+func (t *SignedTx) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	t.decode(d)
+
+	return d.finish()
+}
+
+func (h BlockHeader) MarshalBinary() ([]byte, error) {
+	e := newVersionEncoder(encodingVersionOf(h.encodingVersion))
+	h.encode(e)
+
+	return e.buf.Bytes(), nil
+}
+
+func (h *BlockHeader) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	h.decode(d)
+
+	return d.finish()
+}
+
+// the block TXs are encoded with the block version
+func (b Block) MarshalBinary() ([]byte, error) {
+	e := newVersionEncoder(encodingVersionOf(b.Header.encodingVersion))
+	b.Header.encode(e)
+
+	e.length(len(b.TXs))
+	for _, tx := range b.TXs {
+		tx.encode(e)
+	}
+
+	return e.buf.Bytes(), nil
+}
+
+func (b *Block) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	b.Header.decode(d)
+
+	n := d.length()
+	b.TXs = make([]SignedTx, n)
+	for i := range b.TXs {
+		b.TXs[i].decode(d)
+	}
+
+	return d.finish()
+}
+
+// EncodedSize is the number of bytes the TX adds to a binary encoded block
+func (t SignedTx) EncodedSize() int {
+	e := &encoder{version: t.EncodingVersion()}
+	t.encode(e)
+
+	return e.buf.Len()
+}
+
+// BlockSizeOverhead is the binary encoded size of a block of the miner before its TXs,
+// counting the largest TX count prefix
+func BlockSizeOverhead(miner Account) int {
+	e := newEncoder()
+	BlockHeader{Miner: miner}.encode(e)
+
+	return e.buf.Len() + binary.MaxVarintLen64
+}
+
+// EncodeBlocks encodes a list of blocks, as exchanged between nodes
+func EncodeBlocks(blocks []Block) ([]byte, error) {
+	e := newEncoder()
+
+	e.length(len(blocks))
+	for _, b := range blocks {
+		blockBin, err := b.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		e.bytes(blockBin)
+	}
+
+	return e.buf.Bytes(), nil
+}
+
+func DecodeBlocks(data []byte) ([]Block, error) {
+	d := newDecoder(data)
+
+	n := d.length()
+	blocks := make([]Block, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		var b Block
+		if err := b.UnmarshalBinary(d.bytes()); err != nil {
+			return nil, fmt.Errorf("error while decoding block %d: %w", i, err)
+		}
+		blocks = append(blocks, b)
+	}
+
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// EncodeTXs encodes a list of TXs, as the pending TXs exchanged between nodes
+func EncodeTXs(txs []SignedTx) ([]byte, error) {
+	e := newEncoder()
+
+	e.length(len(txs))
+	for _, tx := range txs {
+		txBin, err := tx.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		e.bytes(txBin)
+	}
+
+	return e.buf.Bytes(), nil
+}
+
+func DecodeTXs(data []byte) ([]SignedTx, error) {
+	d := newDecoder(data)
+
+	n := d.length()
+	txs := make([]SignedTx, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		var tx SignedTx
+		if err := tx.UnmarshalBinary(d.bytes()); err != nil {
+			return nil, fmt.Errorf("error while decoding TX %d: %w", i, err)
+		}
+		txs = append(txs, tx)
+	}
+
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+
+	return txs, nil
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBlockBinaryEncodingRoundTrip(t *testing.T) {
+	txs := []SignedTx{
+		NewSignedTx(Tx{From: "andrej", To: "babayaga", Value: 100, Fee: 1, Nonce: 1, Time: 1600000000, LockHeight: 12, LockTime: 1700000000}, []byte{1, 2, 3}),
+		NewSignedTx(Tx{From: "andrej", To: "caesar", Value: 5, Nonce: 2, Data: "reward", Time: 1600000001}, nil),
+	}
+
+	b, err := NewBlock(Hash{1}, 7, 42, 1600000002, 12, Hash{2}, "andrej", txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocksBin, err := EncodeBlocks([]Block{b, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeBlocks(blocksBin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(decoded) != 2 || !reflect.DeepEqual(decoded[1], b) {
+		t.Fatalf("decoded block %+v differs from %+v", decoded, b)
+	}
+
+	if err := decoded[0].UnmarshalBinary(append([]byte{EncodingVersion + 1}, blocksBin[1:]...)); err == nil {
+		t.Fatal("an unknown encoding version should be rejected")
+	}
+
+	txsBin, err := EncodeTXs(txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decodedTXs, err := DecodeTXs(txsBin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decodedTXs, txs) {
+		t.Fatalf("decoded TXs %+v differ from %+v", decodedTXs, txs)
+	}
+}
+
+// the TX hash must only change together with EncodingVersion
+func TestTxHashIsStable(t *testing.T) {
+	tx := Tx{From: "andrej", To: "babayaga", Value: 100, Fee: 1, Nonce: 1, Time: 1600000000}
+
+	txBin, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "0406616e6472656a08626162617961676100000000000000640000000000000001000000000000000100000000005f5e100000000000000000000000000000000000"
+	if hex.EncodeToString(txBin) != expected {
+		t.Fatalf("TX encoding changed to %x", txBin)
+	}
+}
+
+// blocks of one signed TX of 5 TBB and fee 1 TBB from fixtureAccount,
+// encoded by the nodes of each older encoding version, with their block hash
+const fixtureAccount = "0xcff0c7252f29c2f7f18aca20c79dd8202f5a98b5"
+
+var olderVersionBlocks = []struct {
+	version byte
+	block   string
+	hash    string
+}{
+	{1, "01010000000000000000000000000000000000000000000000000000000000000000000000000000070000002a000000005f5e10020000000ca92a051ec07cc52f985c599ea08a22b61426b4993a87e3131009a9b5553a150e020000000000000000000000000000000000000000000000000000000000000006616e6472656a012a3078636666306337323532663239633266376631386163613230633739646438323032663561393862350862616261796167610000000000000005000000000000000100000000000000010766697874757265000000005f5e1000411c8005a95840168ed4a782b652000f567c828b3c4a5143062f2f448d94c6189f5f1549544ba705aa4a9a97775c5abea1262f5c6fc987c1fbdae51fd966875233e5", "92ec472abaf0b39049ef7d62e429ab03ebc026a76c8851a82be4a60a2811877e"},
+	{2, "02010000000000000000000000000000000000000000000000000000000000000000000000000000070000002a000000005f5e10020000000cbd6e17efdee34e4dd664295b7efb09f973b162bc63ae707a64c167fde9deb20c020000000000000000000000000000000000000000000000000000000000000006616e6472656a012a307863666630633732353266323963326637663138616361323063373964643832303266356139386235086261626179616761000000001dcd65000000000005f5e10000000000000000010766697874757265000000005f5e1000411c9b3b3587fb9fea8395d9bf9db358ac155f60551c38e04485407211eec9924f6457f36ada77612c125ca133f5d30d67a975bad4bc24a19e57a65c353d270c509a", "c95385c3bf54567396335ab655e8420fd86d97b3f6c87d117fb805f5579b2a14"},
+	{3, "03010000000000000000000000000000000000000000000000000000000000000000000000000000070000002a000000005f5e10020000000c211b3a445f96058b63d2e888752833b5b409bf1945fd3633e3bd43e66554674b020000000000000000000000000000000000000000000000000000000000000006616e6472656a012a307863666630633732353266323963326637663138616361323063373964643832303266356139386235086261626179616761000000001dcd65000000000005f5e10000000000000000010766697874757265000000005f5e1000411bcbfd9df472ac47dc5725b5911757c588f544922e5d83a2ce5912d3372bf005c6788ff7e5b0d10e0a3f6de896d2ca1e1f7022f84de6781ed1196e2e91b43154a5000000000000", "e67061efc46f45b5ec84ed4dc20eea4704e6b263cc69484e0e7f46bcae82b8c9"},
+}
+
+func TestDecodesOlderEncodingVersions(t *testing.T) {
+	for _, fixture := range olderVersionBlocks {
+		blockBin, err := hex.DecodeString(fixture.block)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var b Block
+		if err := b.UnmarshalBinary(blockBin); err != nil {
+			t.Fatalf("version %d: %s", fixture.version, err)
+		}
+
+		if b.Header.Number != 7 || b.Header.Miner != "andrej" || len(b.TXs) != 1 {
+			t.Fatalf("version %d: unexpected block %+v", fixture.version, b)
+		}
+
+		tx := b.TXs[0]
+		if tx.From != fixtureAccount || tx.Value != 5*TBB || tx.Fee != TBB || tx.Data != "fixture" || tx.HasLock() || tx.Multisig != nil {
+			t.Fatalf("version %d: unexpected TX %+v", fixture.version, tx)
+		}
+
+		if tx.EncodingVersion() != fixture.version {
+			t.Fatalf("version %d: TX keeps version %d", fixture.version, tx.EncodingVersion())
+		}
+
+		// hashed with its own version again, the block and its TX signature stay valid
+		hash, err := b.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash.Hex() != fixture.hash {
+			t.Fatalf("version %d: block hash changed to %s", fixture.version, hash.Hex())
+		}
+
+		if isAuthentic, err := tx.IsAuthentic(); err != nil || !isAuthentic {
+			t.Fatalf("version %d: TX signature should still verify, got %v", fixture.version, err)
+		}
+
+		if txRoot, err := TxRoot(b.TXs); err != nil || txRoot != b.Header.TxRoot {
+			t.Fatalf("version %d: TX root should still match, got %v", fixture.version, err)
+		}
+
+		encoded, err := b.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(encoded) != fixture.block {
+			t.Fatalf("version %d: block encoding changed to %x", fixture.version, encoded)
+		}
+	}
+}
+
+func TestAddBlockRejectsWholeTBBBlocks(t *testing.T) {
+	state, _ := createTestState(t, fmt.Sprintf(`{"chain_id": "test-chain", "difficulty": %d}`, testDifficulty))
+	defer state.Close()
+
+	blockBin, err := hex.DecodeString(olderVersionBlocks[0].block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b Block
+	if err := b.UnmarshalBinary(blockBin); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := state.AddBlock(b); err == nil || !strings.Contains(err.Error(), "counting whole TBB") {
+		t.Fatalf("a version 1 block should be rejected with a rebuild error, got %v", err)
+	}
+}
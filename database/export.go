@@ -0,0 +1,190 @@
+package database
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// a chain export starts with a ChainExportHeader record followed by one
+// record per block, all framed like the block.db records
+const chainExportMagic = "tbb-chain-export"
+
+// importBatchSize is the number of blocks added to the State at once
+const importBatchSize = 100
+
+// ChainExportHeader describes the exported blocks and the chain they belong to
+type ChainExportHeader struct {
+	ChainID     string
+	GenesisHash Hash
+	From        uint64
+	To          uint64
+
+	// the older encoding version of a decoded header, 0 for the current one
+	encodingVersion byte
+}
+
+func (h ChainExportHeader) MarshalBinary() ([]byte, error) {
+	enc := newEncoder()
+	enc.string(chainExportMagic)
+	enc.string(h.ChainID)
+	enc.hash(h.GenesisHash)
+	enc.uint64(h.From)
+	enc.uint64(h.To)
+
+	return enc.buf.Bytes(), nil
+}
+
+func (h *ChainExportHeader) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	if magic := d.string(); d.err == nil && magic != chainExportMagic {
+		return fmt.Errorf("not a chain export file")
+	}
+
+	h.ChainID = d.string()
+	h.GenesisHash = d.hash()
+	h.From = d.uint64()
+	h.To = d.uint64()
+	h.encodingVersion = d.olderVersion()
+
+	return d.finish()
+}
+
+type ChainImportReport struct {
+	Header ChainExportHeader
+	// blocks added to the main chain
+	Imported uint64
+	Skipped  uint64
+	// blocks only kept as side blocks of a lighter branch
+	SideBlocks uint64
+}
+
+// ExportChain writes the main chain blocks from the number to the number included
+func (s *State) ExportChain(w io.Writer, from uint64, to uint64) (ChainExportHeader, error) {
+	if !s.hasGenesisBlock || to > s.latestBlock.Header.Number || from > to {
+		return ChainExportHeader{}, fmt.Errorf("blocks '%d' to '%d' are not in the main chain", from, to)
+	}
+
+	header := ChainExportHeader{ChainID: s.genesis.ChainID, GenesisHash: s.genesis.Hash(), From: from, To: to}
+	payload, err := header.MarshalBinary()
+	if err != nil {
+		return ChainExportHeader{}, err
+	}
+
+	if _, err := w.Write(encodeRecordFrame(payload)); err != nil {
+		return ChainExportHeader{}, fmt.Errorf("error while writing chain export: %w", err)
+	}
+
+	err = s.store.Iterate(from, to, func(_ Hash, b Block) error {
+		record, err := encodeBlockRecord(b)
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(record); err != nil {
+			return fmt.Errorf("error while writing chain export: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return ChainExportHeader{}, err
+	}
+
+	return header, nil
+}
+
+// ImportChain fully validates and adds the exported blocks. The blocks
+// already in the main chain are skipped, so an interrupted import is resumed
+// by importing the same file again.
+func (s *State) ImportChain(r io.Reader) (ChainImportReport, error) {
+	reader := bufio.NewReader(r)
+	report := ChainImportReport{}
+
+	payload, _, err := readRecordFrame(reader)
+	if err != nil {
+		return report, fmt.Errorf("error while reading chain export header: %w", err)
+	}
+
+	if err := report.Header.UnmarshalBinary(payload); err != nil {
+		return report, err
+	}
+
+	// an older node hashed the genesis with its own encoding version
+	if report.Header.GenesisHash != s.genesis.hashAt(encodingVersionOf(report.Header.encodingVersion)) {
+		return report, fmt.Errorf("blocks of chain '%s' with genesis '%s' can't be imported into chain '%s' with genesis '%s'", report.Header.ChainID, report.Header.GenesisHash.Hex(), s.genesis.ChainID, s.genesis.Hash().Hex())
+	}
+
+	batch := make([]Block, 0, importBatchSize)
+	batchHashes := make([]Hash, 0, importBatchSize)
+	// side blocks of the previous batches, a later block may still make their branch the main chain
+	var sideHashes []Hash
+	addBatch := func() error {
+		err := s.AddBlocks(batch)
+
+		// the blocks before an invalid one are still added
+		added := append(sideHashes, batchHashes...)
+		sideHashes = nil
+		for _, hash := range added {
+			if s.IsMainChainBlock(hash) {
+				report.Imported++
+			} else if s.IsKnownBlock(hash) {
+				sideHashes = append(sideHashes, hash)
+			}
+		}
+		report.SideBlocks = uint64(len(sideHashes))
+		batch = batch[:0]
+		batchHashes = batchHashes[:0]
+
+		return err
+	}
+
+	for number := report.Header.From; number <= report.Header.To; number++ {
+		b, hash, err := readExportedBlock(reader)
+		if err != nil {
+			if batchErr := addBatch(); batchErr != nil {
+				return report, batchErr
+			}
+
+			return report, fmt.Errorf("error while reading block '%d' from chain export: %w", number, err)
+		}
+
+		if s.IsMainChainBlock(hash) {
+			report.Skipped++
+			continue
+		}
+
+		if len(batch) == 0 && !b.Header.Parent.IsEmpty() && !s.IsKnownBlock(b.Header.Parent) {
+			return report, fmt.Errorf("parent of block '%d' is unknown, the blocks before it must be imported first", number)
+		}
+
+		batch = append(batch, b)
+		batchHashes = append(batchHashes, hash)
+		if len(batch) == importBatchSize {
+			if err := addBatch(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	return report, addBatch()
+}
+
+func readExportedBlock(reader *bufio.Reader) (Block, Hash, error) {
+	payload, _, err := readRecordFrame(reader)
+	if err != nil {
+		return Block{}, Hash{}, err
+	}
+
+	var b Block
+	if err := b.UnmarshalBinary(payload); err != nil {
+		return Block{}, Hash{}, err
+	}
+
+	hash, err := b.Hash()
+	if err != nil {
+		return Block{}, Hash{}, err
+	}
+
+	return b, hash, nil
+}
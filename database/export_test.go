@@ -0,0 +1,103 @@
+package database
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExportImportChain(t *testing.T) {
+	genesis := `{"chain_id": "test-chain", "difficulty": 4, "balances": {"andrej": 1000}}`
+	state, _ := createTestState(t, genesis)
+	defer state.Close()
+
+	for i := 0; i < 3; i++ {
+		mineTestBlock(t, state, "andrej", nil)
+	}
+
+	partial, full := new(bytes.Buffer), new(bytes.Buffer)
+	if _, err := state.ExportChain(partial, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := state.ExportChain(full, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, _ := createTestState(t, genesis)
+	defer imported.Close()
+
+	report, err := imported.ImportChain(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 2 || report.Skipped != 0 {
+		t.Fatalf("2 blocks should be imported, got %+v", report)
+	}
+
+	report, err = imported.ImportChain(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 1 || report.Skipped != 2 {
+		t.Fatalf("import should resume after the 2 imported blocks, got %+v", report)
+	}
+
+	if imported.LatestBlockHash() != state.LatestBlockHash() {
+		t.Fatal("imported chain should match the exported one")
+	}
+
+	other, _ := createTestState(t, `{"chain_id": "other", "difficulty": 4, "balances": {"andrej": 1000}}`)
+	defer other.Close()
+
+	full.Reset()
+	if _, err := state.ExportChain(full, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.ImportChain(full); err == nil {
+		t.Fatal("blocks of another genesis should not be imported")
+	}
+}
+
+func TestImportChainReportsSideBlocks(t *testing.T) {
+	genesis := `{"chain_id": "test-chain", "difficulty": 4, "balances": {"andrej": 1000}}`
+	state, _ := createTestState(t, genesis)
+	defer state.Close()
+
+	for i := 0; i < 4; i++ {
+		mineTestBlock(t, state, "caesar", nil)
+	}
+
+	partial, full := new(bytes.Buffer), new(bytes.Buffer)
+	if _, err := state.ExportChain(partial, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := state.ExportChain(full, 0, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, _ := createTestState(t, genesis)
+	defer imported.Close()
+
+	for i := 0; i < 3; i++ {
+		mineTestBlock(t, imported, "andrej", nil)
+	}
+
+	report, err := imported.ImportChain(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 0 || report.SideBlocks != 2 {
+		t.Fatalf("the blocks of the lighter branch should be reported as side blocks, got %+v", report)
+	}
+
+	report, err = imported.ImportChain(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 4 || report.SideBlocks != 0 {
+		t.Fatalf("the heavier branch should be imported into the main chain, got %+v", report)
+	}
+
+	if imported.LatestBlockHash() != state.LatestBlockHash() {
+		t.Fatal("imported chain should match the exported one")
+	}
+}
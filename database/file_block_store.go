@@ -0,0 +1,392 @@
+package database
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// every block.db and journal.db record is framed as
+// magic byte | payload length uint32 | payload CRC-32 uint32 | binary encoded payload
+const (
+	recordMagic      = 0xfb
+	recordHeaderSize = 1 + 4 + 4
+	maxRecordSize    = 64 << 20
+)
+
+var (
+	errTornRecord    = errors.New("record is incomplete")
+	errCorruptRecord = errors.New("record is corrupt")
+)
+
+// FileBlockStore appends the blocks as checksummed records to block.db,
+// with block.idx indexing their offsets. Files written before the framing
+// hold one JSON line per block, still readable.
+type FileBlockStore struct {
+	dbFile  *os.File
+	index   *blockIndex
+	numbers map[Hash]uint64
+}
+
+// NewFileBlockStore opens the block.db and block.idx files of the dir,
+// rebuilding the index from block.db if it's missing or doesn't match it
+func NewFileBlockStore(dir string) (*FileBlockStore, error) {
+	f, err := os.OpenFile(filepath.Join(dir, blockDBFileName), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening block.db file: %w", err)
+	}
+
+	index, err := openBlockIndex(filepath.Join(dir, blockIndexFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	store := &FileBlockStore{dbFile: f, index: index}
+
+	if !store.isIndexValid() {
+		if err := store.rebuildIndex(); err != nil {
+			return nil, err
+		}
+	}
+
+	store.numbers = make(map[Hash]uint64)
+	for number, record := range index.records {
+		store.numbers[record.hash] = uint64(number)
+	}
+
+	return store, nil
+}
+
+// isIndexValid checks the offsets increase and the latest
+// indexed block is the latest block.db record
+func (f *FileBlockStore) isIndexValid() bool {
+	info, err := f.dbFile.Stat()
+	if err != nil {
+		return false
+	}
+
+	records := f.index.records
+	if len(records) == 0 {
+		return info.Size() == 0
+	}
+
+	for i := 1; i < len(records); i++ {
+		if records[i].offset <= records[i-1].offset {
+			return false
+		}
+	}
+
+	latest := records[len(records)-1]
+	if latest.offset >= info.Size() {
+		return false
+	}
+
+	blockFS, size, err := readBlockRecord(bufio.NewReader(io.NewSectionReader(f.dbFile, latest.offset, info.Size()-latest.offset)))
+	if err != nil || latest.offset+size != info.Size() {
+		return false
+	}
+
+	return blockFS.Key == latest.hash
+}
+
+// rebuildIndex scans block.db. A torn record at its end, left by a crash
+// in the middle of a write, is cut off and reported. Any other unreadable
+// record fails the open, block.db is left for the operator to inspect.
+func (f *FileBlockStore) rebuildIndex() error {
+	info, err := f.dbFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(f.dbFile, 0, info.Size()))
+	records := make([]blockIndexRecord, 0)
+	offset := int64(0)
+
+	for offset < info.Size() {
+		blockFS, size, err := readBlockRecord(reader)
+		if err == io.EOF {
+			break
+		}
+
+		// a record ending past block.db with a valid record after it has a corrupt length
+		isTail := (err == errTornRecord && !f.hasRecordAfter(offset, info.Size())) || (err == errCorruptRecord && offset+size == info.Size())
+		if isTail {
+			fmt.Printf("WARNING: block.db ends with a torn block record at offset %d, removing its %d bytes\n", offset, info.Size()-offset)
+
+			if err := f.dbFile.Truncate(offset); err != nil {
+				return fmt.Errorf("error while repairing block.db: %w", err)
+			}
+			if err := f.dbFile.Sync(); err != nil {
+				return fmt.Errorf("error while repairing block.db: %w", err)
+			}
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("error while reading block.db at offset %d: %w", offset, err)
+		}
+
+		records = append(records, blockIndexRecord{blockFS.Key, offset})
+		offset += size
+	}
+
+	return f.index.rebuild(records)
+}
+
+// hasRecordAfter tells if a framed record with a valid checksum starts
+// between the offset and the end of block.db
+func (f *FileBlockStore) hasRecordAfter(offset int64, fileSize int64) bool {
+	reader := bufio.NewReader(io.NewSectionReader(f.dbFile, offset+1, fileSize-offset-1))
+
+	for pos := offset + 1; pos < fileSize; pos++ {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return false
+		}
+
+		if b != recordMagic {
+			continue
+		}
+
+		if _, _, err := readRecordFrame(bufio.NewReader(io.NewSectionReader(f.dbFile, pos, fileSize-pos))); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func encodeBlockRecord(b Block) ([]byte, error) {
+	payload, err := b.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeRecordFrame(payload), nil
+}
+
+func encodeRecordFrame(payload []byte) []byte {
+	record := make([]byte, recordHeaderSize, recordHeaderSize+len(payload))
+	record[0] = recordMagic
+	binary.BigEndian.PutUint32(record[1:5], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[5:9], crc32.ChecksumIEEE(payload))
+
+	return append(record, payload...)
+}
+
+// readRecordFrame reads a framed record payload and returns the record size,
+// known even for a corrupt record
+func readRecordFrame(reader *bufio.Reader) ([]byte, int64, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, 0, errTornRecord
+	}
+
+	if header[0] != recordMagic {
+		return nil, 0, errCorruptRecord
+	}
+
+	length := binary.BigEndian.Uint32(header[1:5])
+	if length == 0 || length > maxRecordSize {
+		return nil, 0, fmt.Errorf("%w: length %d", errCorruptRecord, length)
+	}
+
+	size := recordHeaderSize + int64(length)
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, size, errTornRecord
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[5:9]) {
+		return nil, size, errCorruptRecord
+	}
+
+	return payload, size, nil
+}
+
+// readBlockRecord reads the next block.db record and returns its size in bytes,
+// known even for a corrupt record. It returns io.EOF at the end of the file.
+func readBlockRecord(reader *bufio.Reader) (BlockFS, int64, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return BlockFS{}, 0, io.EOF
+	}
+
+	var payload []byte
+	var size int64
+
+	switch first[0] {
+	case '{':
+		// legacy JSON line
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return BlockFS{}, int64(len(line)), errTornRecord
+		}
+		if err != nil {
+			return BlockFS{}, 0, err
+		}
+
+		payload = line
+		size = int64(len(line))
+
+	case recordMagic:
+		payload, size, err = readRecordFrame(reader)
+		if err != nil {
+			return BlockFS{}, size, err
+		}
+
+	default:
+		return BlockFS{}, 0, errCorruptRecord
+	}
+
+	blockFS, err := decodeBlockPayload(payload)
+	if err != nil {
+		return BlockFS{}, size, fmt.Errorf("%w: %s", errCorruptRecord, err)
+	}
+
+	return blockFS, size, nil
+}
+
+// decodeBlockPayload decodes a binary encoded block, or a BlockFS JSON
+// written before blocks had a binary encoding
+func decodeBlockPayload(payload []byte) (BlockFS, error) {
+	if payload[0] == '{' {
+		var blockFS BlockFS
+		err := json.Unmarshal(payload, &blockFS)
+
+		return blockFS, err
+	}
+
+	var b Block
+	if err := b.UnmarshalBinary(payload); err != nil {
+		return BlockFS{}, err
+	}
+
+	hash, err := b.Hash()
+	if err != nil {
+		return BlockFS{}, err
+	}
+
+	return BlockFS{hash, b}, nil
+}
+
+// Append writes the block record and syncs block.db before returning
+func (f *FileBlockStore) Append(hash Hash, b Block) error {
+	record, err := encodeBlockRecord(b)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Persisting new Block '%s' to disk\n", hash.Hex())
+
+	info, err := f.dbFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.dbFile.Write(record); err != nil {
+		// don't leave a partial record for the next one to follow
+		_ = f.dbFile.Truncate(info.Size())
+		return err
+	}
+
+	if err := f.dbFile.Sync(); err != nil {
+		return fmt.Errorf("error while syncing block.db: %w", err)
+	}
+
+	f.numbers[hash] = f.Len()
+
+	// the index is only a cache of block.db, rebuilt when opened again if a write failed
+	if err := f.index.append(hash, info.Size()); err != nil {
+		fmt.Printf("WARNING: %s, block index will be rebuilt\n", err)
+	}
+
+	return nil
+}
+
+func (f *FileBlockStore) Iterate(from uint64, to uint64, fn func(hash Hash, b Block) error) error {
+	if from >= f.Len() || from > to {
+		return nil
+	}
+
+	if to >= f.Len() {
+		to = f.Len() - 1
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(f.dbFile, f.index.records[from].offset, math.MaxInt64))
+
+	for number := from; number <= to; number++ {
+		blockFS, _, err := readBlockRecord(reader)
+		if err != nil {
+			return fmt.Errorf("error while reading block '%d' from block.db: %w", number, err)
+		}
+
+		if blockFS.Key != f.index.records[number].hash {
+			return fmt.Errorf("block.db holds block '%s' instead of '%s' at height %d", blockFS.Key.Hex(), f.index.records[number].hash.Hex(), number)
+		}
+
+		if err := fn(blockFS.Key, blockFS.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *FileBlockStore) GetByHash(hash Hash) (Block, error) {
+	number, ok := f.numbers[hash]
+	if !ok {
+		return Block{}, fmt.Errorf("block '%s' not found", hash.Hex())
+	}
+
+	var block Block
+	err := f.Iterate(number, number, func(_ Hash, b Block) error {
+		block = b
+		return nil
+	})
+
+	return block, err
+}
+
+func (f *FileBlockStore) Truncate(from uint64) error {
+	if from >= f.Len() {
+		return nil
+	}
+
+	if err := f.dbFile.Truncate(f.index.records[from].offset); err != nil {
+		return fmt.Errorf("error while truncating block.db: %w", err)
+	}
+
+	if err := f.dbFile.Sync(); err != nil {
+		return fmt.Errorf("error while syncing block.db: %w", err)
+	}
+
+	for number := from; number < f.Len(); number++ {
+		delete(f.numbers, f.index.records[number].hash)
+	}
+
+	if err := f.index.truncate(from); err != nil {
+		fmt.Printf("WARNING: %s, block index will be rebuilt\n", err)
+	}
+
+	return nil
+}
+
+func (f *FileBlockStore) Len() uint64 {
+	return uint64(len(f.index.records))
+}
+
+func (f *FileBlockStore) Close() error {
+	if err := f.index.close(); err != nil {
+		return err
+	}
+
+	return f.dbFile.Close()
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileBlockStoreRepairsTornTail(t *testing.T) {
+	state, dataDir := createTestState(t, `{"chain_id": "test-chain", "difficulty": 4, "balances": {}}`)
+	mineTestBlock(t, state, "andrej", nil)
+	latest := mineTestBlock(t, state, "andrej", nil)
+	latestHash := state.LatestBlockHash()
+	state.Close()
+
+	info, err := os.Stat(getBlockDBFilePath(dataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash in the middle of writing the third record
+	record, err := encodeBlockRecord(latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendToFile(t, getBlockDBFilePath(dataDir), record[:len(record)/2])
+
+	state, err = NewStateFromDisk(dataDir)
+	if err != nil {
+		t.Fatalf("torn tail should be repaired, got: %s", err)
+	}
+	defer state.Close()
+
+	if state.LatestBlockHash() != latestHash {
+		t.Fatal("blocks before the torn record should be kept")
+	}
+
+	repaired, err := os.Stat(getBlockDBFilePath(dataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if repaired.Size() != info.Size() {
+		t.Fatalf("block.db should be cut back to %d bytes, got %d", info.Size(), repaired.Size())
+	}
+}
+
+func TestFileBlockStoreRejectsCorruptLengthBeforeValidRecords(t *testing.T) {
+	state, dataDir := createTestState(t, `{"chain_id": "test-chain", "difficulty": 4, "balances": {}}`)
+	for i := 0; i < 3; i++ {
+		mineTestBlock(t, state, "andrej", nil)
+	}
+	state.Close()
+
+	dbPath := getBlockDBFilePath(dataDir)
+	content, err := ioutil.ReadFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second record length points past the end of block.db
+	second := recordHeaderSize + int(binary.BigEndian.Uint32(content[1:5]))
+	binary.BigEndian.PutUint32(content[second+1:second+5], uint32(len(content)-second))
+	if err := ioutil.WriteFile(dbPath, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(getBlockIndexFilePath(dataDir)); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, err := NewStateFromDisk(dataDir); err == nil {
+		state.Close()
+		t.Fatal("a corrupt record followed by valid ones should fail the open")
+	}
+
+	after, err := ioutil.ReadFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(after) != len(content) {
+		t.Fatalf("block.db should be left untouched, %d of its %d bytes are left", len(after), len(content))
+	}
+}
+
+func TestFileBlockStoreReadsLegacyJSONLines(t *testing.T) {
+	state, _ := createTestState(t, `{"chain_id": "test-chain", "difficulty": 4, "balances": {}}`)
+	b := mineTestBlock(t, state, "andrej", nil)
+	hash := state.LatestBlockHash()
+	state.Close()
+
+	dir, err := ioutil.TempDir("", "tbb_legacy_store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	line, err := json.Marshal(BlockFS{hash, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendToFile(t, filepath.Join(dir, blockDBFileName), append(line, '\n'))
+
+	store, err := NewFileBlockStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if store.Len() != 1 {
+		t.Fatalf("expected 1 legacy block, got %d", store.Len())
+	}
+
+	if _, err := store.GetByHash(hash); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendToFile(t *testing.T, path string, content []byte) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(content); err != nil {
+		t.Fatal(err)
+	}
+}
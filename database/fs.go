@@ -1,12 +1,19 @@
 package database
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 )
 
+const (
+	blockDBFileName    = "block.db"
+	blockIndexFileName = "block.idx"
+)
+
 func getDatabaseDirPath(dataDir string) string {
 	return filepath.Join(dataDir, "database")
 }
@@ -16,7 +23,27 @@ func getGenesisJSONFilePath(dataDir string) string {
 }
 
 func getBlockDBFilePath(dataDir string) string {
-	return filepath.Join(getDatabaseDirPath(dataDir), "block.db")
+	return filepath.Join(getDatabaseDirPath(dataDir), blockDBFileName)
+}
+
+func getBlockIndexFilePath(dataDir string) string {
+	return filepath.Join(getDatabaseDirPath(dataDir), blockIndexFileName)
+}
+
+func getJournalFilePath(dataDir string) string {
+	return filepath.Join(getDatabaseDirPath(dataDir), "journal.db")
+}
+
+func getTxIndexFilePath(dataDir string) string {
+	return filepath.Join(getDatabaseDirPath(dataDir), "txindex.db")
+}
+
+func getAccountIndexFilePath(dataDir string) string {
+	return filepath.Join(getDatabaseDirPath(dataDir), "accountindex.db")
+}
+
+func getSnapshotDirPath(dataDir string) string {
+	return filepath.Join(getDatabaseDirPath(dataDir), "snapshots")
 }
 
 func fileExist(filePath string) bool {
@@ -31,7 +58,9 @@ func writeEmptyBlockDBToDisk(path string) error {
 	return ioutil.WriteFile(path, []byte(""), os.ModePerm)
 }
 
-func initDataDirIfNotExists(dataDir string) error {
+// InitDataDirIfNotExists creates the database dir with the given genesis
+// and an empty block.db, unless the data dir already holds a genesis file
+func InitDataDirIfNotExists(dataDir string, genesis []byte) error {
 	if fileExist(getGenesisJSONFilePath(dataDir)) {
 		return nil
 	}
@@ -40,7 +69,7 @@ func initDataDirIfNotExists(dataDir string) error {
 		return fmt.Errorf("error while creating database directory: %w", err)
 	}
 
-	if err := writeGenesisToDisk(getGenesisJSONFilePath(dataDir)); err != nil {
+	if err := writeGenesisToDisk(getGenesisJSONFilePath(dataDir), genesis); err != nil {
 		return fmt.Errorf("error while writing genesis block to file: %w", err)
 	}
 
@@ -50,3 +79,38 @@ func initDataDirIfNotExists(dataDir string) error {
 
 	return nil
 }
+
+// InitDataDir creates the database dir with a validated genesis. An existing
+// chain is only replaced, together with all its blocks, when forced.
+func InitDataDir(dataDir string, genesis []byte, force bool) (Genesis, error) {
+	decoder := json.NewDecoder(bytes.NewReader(genesis))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&Genesis{}); err != nil {
+		return Genesis{}, fmt.Errorf("invalid genesis: %w", err)
+	}
+
+	gen, err := ParseGenesis(genesis)
+	if err != nil {
+		return Genesis{}, err
+	}
+
+	if err := gen.Validate(); err != nil {
+		return Genesis{}, fmt.Errorf("invalid genesis: %w", err)
+	}
+
+	if fileExist(getGenesisJSONFilePath(dataDir)) {
+		if !force {
+			return Genesis{}, fmt.Errorf("data dir '%s' already holds a chain, force the init to overwrite it", dataDir)
+		}
+
+		if err := os.RemoveAll(getDatabaseDirPath(dataDir)); err != nil {
+			return Genesis{}, fmt.Errorf("error while removing existing chain: %w", err)
+		}
+	}
+
+	if err := InitDataDirIfNotExists(dataDir, genesis); err != nil {
+		return Genesis{}, err
+	}
+
+	return gen, nil
+}
@@ -1,21 +1,71 @@
 package database
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"sort"
+	"time"
 )
 
+const DefaultBlockReward = 100 * TBB
+const DefaultMiningInterval = 10
+const DefaultMedianTimeBlocks = 11
+const DefaultMaxFutureBlockTime = 2 * 60 * 60
+const DefaultMaxBlockSize = 1 << 20
+const DefaultMaxBlockTXs = 1000
+const DefaultHalvingInterval = 100000
+const DefaultMaxSupply = 21000000 * TBB
+
 var genesisJSON = `{
 	"genesis_time": "2019-03-18T00:00:00.000000000Z",
 	"chain_id": "the-blockchain-bar-ledger",
+	"difficulty": 24,
+	"block_reward": 100,
+	"target_block_time": 30,
+	"retarget_interval": 10,
+	"mining_interval": 10,
+	"median_time_blocks": 11,
+	"max_future_block_time": 7200,
+	"max_block_size": 1048576,
+	"max_block_txs": 1000,
+	"halving_interval": 100000,
+	"max_supply": 21000000,
 	"balances": {
 	  "andrej": 1000000
 	}
 }`
 
 type Genesis struct {
-	Balances map[Account]uint `json:"balances"`
+	// nodes only sync with peers of the same chain
+	ChainID string    `json:"chain_id"`
+	Time    time.Time `json:"genesis_time"`
+
+	Balances map[Account]Amount `json:"balances"`
+
+	// initial PoW difficulty in leading zero bits
+	Difficulty uint32 `json:"difficulty"`
+	// TBB credited to the miner of every block before the first halving, on top of the TX fees
+	BlockReward Amount `json:"block_reward"`
+	// number of blocks after which the block reward halves
+	HalvingInterval uint64 `json:"halving_interval"`
+	// TBB ever issued, genesis balances included, no block reward exceeds it
+	MaxSupply Amount `json:"max_supply"`
+	// seconds a block should take to mine, difficulty is retargeted towards it
+	TargetBlockTime uint64 `json:"target_block_time"`
+	// number of blocks between two difficulty retargets
+	RetargetInterval uint64 `json:"retarget_interval"`
+	// seconds between two attempts of a node to mine its pending TXs
+	MiningInterval uint64 `json:"mining_interval"`
+	// a block must be later than the median time of this many latest blocks
+	MedianTimeBlocks uint64 `json:"median_time_blocks"`
+	// seconds a block time may be ahead of the clock of the node validating it
+	MaxFutureBlockTime uint64 `json:"max_future_block_time"`
+	// maximum size of a binary encoded block in bytes
+	MaxBlockSize uint64 `json:"max_block_size"`
+	// maximum number of TXs of a block
+	MaxBlockTXs uint64 `json:"max_block_txs"`
 }
 
 func LoadGenesis(path string) (Genesis, error) {
@@ -24,14 +74,146 @@ func LoadGenesis(path string) (Genesis, error) {
 		return Genesis{}, fmt.Errorf("error while reading genesisDb file: %w", err)
 	}
 
+	gen, err := ParseGenesis(content)
+	if err != nil {
+		return Genesis{}, err
+	}
+
+	// params like a zero retarget interval would crash the node later on
+	if err := gen.Validate(); err != nil {
+		return Genesis{}, fmt.Errorf("invalid genesis '%s': %w", path, err)
+	}
+
+	return gen, nil
+}
+
+func ParseGenesis(content []byte) (Genesis, error) {
 	var loadedGenesis Genesis
-	if err = json.Unmarshal(content, &loadedGenesis); err != nil {
+	if err := json.Unmarshal(content, &loadedGenesis); err != nil {
 		return Genesis{}, fmt.Errorf("error while unmarshalling genesisblock to struct: %w", err)
 	}
 
+	if _, err := sumBalances(loadedGenesis.Balances); err != nil {
+		return Genesis{}, fmt.Errorf("genesis balances: %w", err)
+	}
+
+	// genesis files written before the consensus params existed lack them,
+	// only the absent ones get their default, an explicit zero is kept
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return Genesis{}, fmt.Errorf("error while unmarshalling genesisblock fields: %w", err)
+	}
+	isAbsent := func(field string) bool {
+		value, ok := fields[field]
+		return !ok || string(value) == "null"
+	}
+
+	if isAbsent("difficulty") {
+		loadedGenesis.Difficulty = DefaultDifficulty
+	}
+	if isAbsent("target_block_time") {
+		loadedGenesis.TargetBlockTime = DefaultTargetBlockTime
+	}
+	if isAbsent("retarget_interval") {
+		loadedGenesis.RetargetInterval = DefaultRetargetInterval
+	}
+	if isAbsent("block_reward") {
+		loadedGenesis.BlockReward = DefaultBlockReward
+	}
+	if isAbsent("halving_interval") {
+		loadedGenesis.HalvingInterval = DefaultHalvingInterval
+	}
+	if isAbsent("max_supply") {
+		loadedGenesis.MaxSupply = DefaultMaxSupply
+	}
+	if isAbsent("mining_interval") {
+		loadedGenesis.MiningInterval = DefaultMiningInterval
+	}
+	if isAbsent("median_time_blocks") {
+		loadedGenesis.MedianTimeBlocks = DefaultMedianTimeBlocks
+	}
+	if isAbsent("max_future_block_time") {
+		loadedGenesis.MaxFutureBlockTime = DefaultMaxFutureBlockTime
+	}
+	if isAbsent("max_block_size") {
+		loadedGenesis.MaxBlockSize = DefaultMaxBlockSize
+	}
+	if isAbsent("max_block_txs") {
+		loadedGenesis.MaxBlockTXs = DefaultMaxBlockTXs
+	}
+
 	return loadedGenesis, nil
 }
 
-func writeGenesisToDisk(path string) error {
-	return ioutil.WriteFile(path, []byte(genesisJSON), 0644)
+func writeGenesisToDisk(path string, genesis []byte) error {
+	return ioutil.WriteFile(path, genesis, 0644)
+}
+
+func (g Genesis) Validate() error {
+	if g.ChainID == "" {
+		return fmt.Errorf("genesis chain_id is required")
+	}
+
+	if g.Difficulty > uint32(len(Hash{})*8) {
+		return fmt.Errorf("genesis difficulty must be at most %d bits, not %d", len(Hash{})*8, g.Difficulty)
+	}
+
+	if g.RetargetInterval == 0 || g.HalvingInterval == 0 || g.MiningInterval == 0 || g.MedianTimeBlocks == 0 {
+		return fmt.Errorf("genesis retarget_interval, halving_interval, mining_interval and median_time_blocks must be at least 1")
+	}
+
+	for account := range g.Balances {
+		if account == "" {
+			return fmt.Errorf("genesis balances contain an empty account")
+		}
+	}
+
+	supply, err := sumBalances(g.Balances)
+	if err != nil {
+		return fmt.Errorf("genesis balances: %w", err)
+	}
+
+	if supply > g.MaxSupply {
+		return fmt.Errorf("genesis balances total %s TBB, more than the max supply %s TBB", supply, g.MaxSupply)
+	}
+
+	return nil
+}
+
+// Hash identifies the chain the genesis starts, computed
+// over its binary encoding with the balances sorted by account
+func (g Genesis) Hash() Hash {
+	return g.hashAt(EncodingVersion)
+}
+
+// hashAt is the genesis hash older encoding versions computed
+func (g Genesis) hashAt(version byte) Hash {
+	e := newVersionEncoder(version)
+	e.string(g.ChainID)
+	e.uint64(uint64(g.Time.UnixNano()))
+	e.uint32(g.Difficulty)
+	e.amount(g.BlockReward)
+	e.uint64(g.HalvingInterval)
+	e.amount(g.MaxSupply)
+	e.uint64(g.TargetBlockTime)
+	e.uint64(g.RetargetInterval)
+	e.uint64(g.MiningInterval)
+	e.uint64(g.MedianTimeBlocks)
+	e.uint64(g.MaxFutureBlockTime)
+	e.uint64(g.MaxBlockSize)
+	e.uint64(g.MaxBlockTXs)
+
+	accounts := make([]string, 0, len(g.Balances))
+	for account := range g.Balances {
+		accounts = append(accounts, string(account))
+	}
+	sort.Strings(accounts)
+
+	e.length(len(accounts))
+	for _, account := range accounts {
+		e.string(account)
+		e.amount(g.Balances[Account(account)])
+	}
+
+	return sha256.Sum256(e.buf.Bytes())
 }
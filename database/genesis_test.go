@@ -0,0 +1,132 @@
+package database
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGenesisConsensusParams(t *testing.T) {
+	gen, err := ParseGenesis([]byte(`{"chain_id": "test-chain", "genesis_time": "2019-03-18T00:00:00Z", "difficulty": 4, "block_reward": 7, "balances": {}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gen.ChainID != "test-chain" || gen.TargetBlockTime != DefaultTargetBlockTime || gen.MiningInterval != DefaultMiningInterval {
+		t.Fatalf("unexpected genesis %+v", gen)
+	}
+
+	state, err := NewStateFromStore(gen, NewMemoryBlockStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mineTestBlock(t, state, "andrej", nil)
+	if state.Balances["andrej"] != 7*TBB {
+		t.Fatalf("miner should be rewarded the genesis block reward, got %s", state.Balances["andrej"])
+	}
+
+	b, err := NewBlock(state.LatestBlockHash(), 1, 0, uint64(gen.Time.Add(-time.Second).Unix()), state.NextBlockDifficulty(), Hash{}, "andrej", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyBlock(b, state); err == nil {
+		t.Fatal("a block older than the genesis should be rejected")
+	}
+}
+
+func TestGenesisKeepsExplicitZeroParams(t *testing.T) {
+	gen, err := ParseGenesis([]byte(`{"chain_id": "test-chain", "difficulty": 0, "block_reward": 0, "max_block_txs": null, "balances": {"andrej": 1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gen.Difficulty != 0 || gen.BlockReward != 0 {
+		t.Fatalf("explicit zero difficulty and block reward should be kept, got %d and %s", gen.Difficulty, gen.BlockReward)
+	}
+
+	if gen.MaxBlockTXs != DefaultMaxBlockTXs || gen.RetargetInterval != DefaultRetargetInterval {
+		t.Fatalf("absent and null params should get their default, got %+v", gen)
+	}
+
+	if err := gen.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := NewStateFromStore(gen, NewMemoryBlockStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mineTestBlock(t, state, "babayaga", nil)
+	if state.Balances["babayaga"] != 0 {
+		t.Fatalf("a zero block reward should not credit the miner, got %s", state.Balances["babayaga"])
+	}
+
+	zeroInterval, err := ParseGenesis([]byte(`{"chain_id": "test-chain", "retarget_interval": 0, "balances": {}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zeroInterval.Validate(); err == nil {
+		t.Fatal("a zero retarget interval should be rejected")
+	}
+}
+
+func TestOpeningDataDirRejectsZeroIntervals(t *testing.T) {
+	for _, field := range []string{"retarget_interval", "mining_interval", "halving_interval", "median_time_blocks"} {
+		dataDir, err := ioutil.TempDir("", "tbb_genesis_test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dataDir)
+
+		genesis := fmt.Sprintf(`{"chain_id": "test-chain", "%s": 0, "balances": {"andrej": 1}}`, field)
+		if err := InitDataDirIfNotExists(dataDir, []byte(genesis)); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewStateFromDisk(dataDir); err == nil {
+			t.Fatalf("loading a genesis with a zero %s should fail", field)
+		}
+
+		if _, err := VerifyChain(dataDir); err == nil {
+			t.Fatalf("verifying a chain whose genesis has a zero %s should fail", field)
+		}
+	}
+}
+
+func TestInitDataDirRefusesToOverwriteChain(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "tbb_init_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	genesis := []byte(`{"chain_id": "test-chain", "balances": {"andrej": 1}}`)
+
+	gen, err := InitDataDir(dataDir, genesis, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := InitDataDir(dataDir, genesis, false); err == nil {
+		t.Fatal("existing chain should not be overwritten unless forced")
+	}
+
+	forced, err := InitDataDir(dataDir, genesis, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if forced.Hash() != gen.Hash() {
+		t.Fatal("same genesis should have the same hash")
+	}
+
+	if _, err := InitDataDir(dataDir, []byte(`{"balances": {}}`), true); err == nil {
+		t.Fatal("genesis without chain ID should be rejected")
+	}
+}
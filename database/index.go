@@ -0,0 +1,127 @@
+package database
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+)
+
+// an index record is the block hash, its block.db offset and a checksum of both
+const blockIndexRecordSize = 32 + 8 + 4
+
+type blockIndexRecord struct {
+	hash   Hash
+	offset int64
+}
+
+// blockIndex persists the offset in block.db of every stored block.
+// The record of block number N is at N * blockIndexRecordSize in block.idx.
+type blockIndex struct {
+	file    *os.File
+	records []blockIndexRecord
+}
+
+// openBlockIndex loads the index, keeping records up to the first corrupt one
+func openBlockIndex(path string) (*blockIndex, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening block.idx file: %w", err)
+	}
+
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading block.idx file: %w", err)
+	}
+
+	idx := &blockIndex{file: f}
+
+	for len(content) >= blockIndexRecordSize {
+		record, ok := decodeBlockIndexRecord(content[:blockIndexRecordSize])
+		if !ok {
+			break
+		}
+
+		idx.records = append(idx.records, record)
+		content = content[blockIndexRecordSize:]
+	}
+
+	return idx, nil
+}
+
+func encodeBlockIndexRecord(record blockIndexRecord) []byte {
+	buf := make([]byte, blockIndexRecordSize)
+	copy(buf, record.hash[:])
+	binary.BigEndian.PutUint64(buf[32:40], uint64(record.offset))
+	binary.BigEndian.PutUint32(buf[40:], crc32.ChecksumIEEE(buf[:40]))
+
+	return buf
+}
+
+func decodeBlockIndexRecord(buf []byte) (blockIndexRecord, bool) {
+	if binary.BigEndian.Uint32(buf[40:]) != crc32.ChecksumIEEE(buf[:40]) {
+		return blockIndexRecord{}, false
+	}
+
+	record := blockIndexRecord{offset: int64(binary.BigEndian.Uint64(buf[32:40]))}
+	copy(record.hash[:], buf[:32])
+
+	return record, true
+}
+
+// append records the block, in memory even if block.idx can't be written
+// as the index is then rebuilt when opened again
+func (idx *blockIndex) append(hash Hash, offset int64) error {
+	record := blockIndexRecord{hash, offset}
+	position := int64(len(idx.records)) * blockIndexRecordSize
+	idx.records = append(idx.records, record)
+
+	_, err := idx.file.WriteAt(encodeBlockIndexRecord(record), position)
+	if err != nil {
+		return fmt.Errorf("error while writing block.idx file: %w", err)
+	}
+
+	return nil
+}
+
+// truncate drops the records of blocks number and above
+func (idx *blockIndex) truncate(number uint64) error {
+	if number > uint64(len(idx.records)) {
+		number = uint64(len(idx.records))
+	}
+
+	idx.records = idx.records[:number]
+
+	if err := idx.file.Truncate(int64(number) * blockIndexRecordSize); err != nil {
+		return fmt.Errorf("error while truncating block.idx file: %w", err)
+	}
+
+	return nil
+}
+
+// rebuild rewrites the whole index, used when it's missing or corrupt
+func (idx *blockIndex) rebuild(records []blockIndexRecord) error {
+	fmt.Println("Rebuilding block index....")
+
+	buf := make([]byte, 0, len(records)*blockIndexRecordSize)
+	for _, record := range records {
+		buf = append(buf, encodeBlockIndexRecord(record)...)
+	}
+
+	if err := idx.truncate(0); err != nil {
+		return err
+	}
+
+	idx.records = records
+
+	if _, err := idx.file.WriteAt(buf, 0); err != nil {
+		return fmt.Errorf("error while writing block.idx file: %w", err)
+	}
+
+	return nil
+}
+
+func (idx *blockIndex) close() error {
+	return idx.file.Close()
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestBlockIndexIsRebuiltWhenCorrupt(t *testing.T) {
+	state, dataDir := createTestState(t, `{"chain_id": "test-chain", "difficulty": 4, "balances": {}}`)
+
+	hashes := make([]Hash, 0)
+	for i := 0; i < 3; i++ {
+		b := mineTestBlock(t, state, "andrej", nil)
+		hash, err := b.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hash)
+	}
+	state.Close()
+
+	// flip a byte of the second record
+	content, err := ioutil.ReadFile(getBlockIndexFilePath(dataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content[blockIndexRecordSize+3] ^= 0xff
+	if err := ioutil.WriteFile(getBlockIndexFilePath(dataDir), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = NewStateFromDisk(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+
+	index := state.store.(*FileBlockStore).index
+	if len(index.records) != len(hashes) || index.records[1].hash != hashes[1] {
+		t.Fatal("corrupt block index should be rebuilt")
+	}
+
+	for number, hash := range hashes {
+		b, err := state.GetBlockByNumber(uint64(number))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		byHash, err := state.GetBlockByHash(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if b.Header.Number != uint64(number) || byHash.Header.Number != uint64(number) {
+			t.Fatalf("block %d lookup returned block %d", number, b.Header.Number)
+		}
+	}
+
+	blocks, err := state.GetBlocksAfter(hashes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 blocks after the first one, got %d", len(blocks))
+	}
+}
@@ -0,0 +1,275 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// balanceDiff is the change a block made to an account's balance and nonce
+type balanceDiff struct {
+	account     Account
+	prevBalance undoValue
+	prevNonce   undoValue
+	balance     Amount
+	nonce       undoValue
+}
+
+// journalEntry records every account a main chain block changed, with the
+// values before and after it, so balances at any height can be computed
+// forward from the genesis or backward from the latest block.
+type journalEntry struct {
+	number                uint64
+	hash                  Hash
+	prevRetargetStartTime uint64
+	diffs                 []balanceDiff
+}
+
+// newJournalEntry builds the block entry from its undo record and the state right after the block
+func newJournalEntry(b Block, hash Hash, undo blockUndo, s *State) journalEntry {
+	entry := journalEntry{number: b.Header.Number, hash: hash, prevRetargetStartTime: undo.retargetStartTime}
+
+	for _, account := range sortedAccounts(undo.balances) {
+		nonce, hasNonce := s.Account2Nonce[account]
+
+		entry.diffs = append(entry.diffs, balanceDiff{
+			account:     account,
+			prevBalance: undo.balances[account],
+			prevNonce:   undo.nonces[account],
+			balance:     s.Balances[account],
+			nonce:       undoValue{uint64(nonce), hasNonce},
+		})
+	}
+
+	return entry
+}
+
+func (e journalEntry) undo() blockUndo {
+	undo := blockUndo{
+		balances:          make(map[Account]undoValue),
+		nonces:            make(map[Account]undoValue),
+		retargetStartTime: e.prevRetargetStartTime,
+	}
+
+	for _, diff := range e.diffs {
+		undo.balances[diff.account] = diff.prevBalance
+		undo.nonces[diff.account] = diff.prevNonce
+	}
+
+	return undo
+}
+
+// apply sets the balances to their values after the block
+func (e journalEntry) apply(balances map[Account]Amount) {
+	for _, diff := range e.diffs {
+		balances[diff.account] = diff.balance
+	}
+}
+
+// revert sets the balances back to their values before the block
+func (e journalEntry) revert(balances map[Account]Amount) {
+	for _, diff := range e.diffs {
+		if diff.prevBalance.existed {
+			balances[diff.account] = Amount(diff.prevBalance.value)
+		} else {
+			delete(balances, diff.account)
+		}
+	}
+}
+
+func encodeUndoValue(e *encoder, v undoValue) {
+	existed := uint32(0)
+	if v.existed {
+		existed = 1
+	}
+
+	e.uint32(existed)
+	e.uint64(v.value)
+}
+
+func decodeUndoValue(d *decoder) undoValue {
+	existed := d.uint32() == 1
+	return undoValue{d.uint64(), existed}
+}
+
+func (e journalEntry) MarshalBinary() ([]byte, error) {
+	enc := newBlockLogEncoder(e.number, e.hash)
+	enc.uint64(e.prevRetargetStartTime)
+
+	enc.length(len(e.diffs))
+	for _, diff := range e.diffs {
+		enc.string(string(diff.account))
+		encodeUndoValue(enc, diff.prevBalance)
+		encodeUndoValue(enc, diff.prevNonce)
+		enc.uint64(uint64(diff.balance))
+		encodeUndoValue(enc, diff.nonce)
+	}
+
+	return enc.buf.Bytes(), nil
+}
+
+func (e *journalEntry) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	e.number = d.uint64()
+	e.hash = d.hash()
+	e.prevRetargetStartTime = d.uint64()
+
+	n := d.length()
+	e.diffs = make([]balanceDiff, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		var diff balanceDiff
+		diff.account = Account(d.string())
+		diff.prevBalance = decodeUndoValue(d)
+		diff.prevNonce = decodeUndoValue(d)
+		diff.balance = Amount(d.uint64())
+		diff.nonce = decodeUndoValue(d)
+		e.diffs = append(e.diffs, diff)
+	}
+
+	return d.finish()
+}
+
+// balanceJournal persists one journalEntry per main chain block to journal.db
+type balanceJournal struct {
+	*blockLog
+}
+
+func newMemoryBalanceJournal() *balanceJournal {
+	return &balanceJournal{newMemoryBlockLog()}
+}
+
+func openBalanceJournal(path string) (*balanceJournal, error) {
+	log, err := openBlockLog(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &balanceJournal{log}, nil
+}
+
+// append adds the entry of the block following the latest journaled one
+func (j *balanceJournal) append(entry journalEntry) error {
+	payload, err := entry.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return j.blockLog.append(entry.number, entry.hash, payload)
+}
+
+func (j *balanceJournal) read(number uint64) (journalEntry, error) {
+	payload, err := j.blockLog.read(number)
+	if err != nil {
+		return journalEntry{}, err
+	}
+
+	var entry journalEntry
+	if err := entry.UnmarshalBinary(payload); err != nil {
+		return journalEntry{}, err
+	}
+
+	return entry, nil
+}
+
+func sortedAccounts(values map[Account]undoValue) []Account {
+	accounts := make([]Account, 0, len(values))
+	for account := range values {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i] < accounts[j] })
+
+	return accounts
+}
+
+// BalancesAt returns the balances right after the main chain block of the number.
+// The journaled balance changes are applied forward from the genesis or
+// reverted backward from the latest block, whichever is closer.
+func (s *State) BalancesAt(number uint64) (map[Account]Amount, error) {
+	if !s.hasGenesisBlock || number > s.latestBlock.Header.Number {
+		return nil, fmt.Errorf("block number '%d' is not in the main chain", number)
+	}
+
+	balances := make(map[Account]Amount)
+	tip := s.latestBlock.Header.Number
+
+	if tip-number <= number {
+		for account, balance := range s.Balances {
+			balances[account] = balance
+		}
+
+		for n := tip; n > number; n-- {
+			entry, err := s.journalEntry(n)
+			if err != nil {
+				return nil, err
+			}
+			entry.revert(balances)
+		}
+	} else {
+		for account, balance := range s.genesis.Balances {
+			balances[account] = balance
+		}
+
+		for n := uint64(0); n <= number; n++ {
+			entry, err := s.journalEntry(n)
+			if err != nil {
+				return nil, err
+			}
+			entry.apply(balances)
+		}
+	}
+
+	return balances, nil
+}
+
+func (s *State) BalancesAtHash(hash Hash) (map[Account]Amount, error) {
+	number, err := s.blockNumber(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.BalancesAt(number)
+}
+
+// BalancesAtBlock returns the balances after the block given either by hash or by number
+func (s *State) BalancesAtBlock(block string) (Hash, map[Account]Amount, error) {
+	number, err := strconv.ParseUint(block, 10, 64)
+	if err != nil {
+		var hash Hash
+		if err := hash.UnmarshalText([]byte(block)); err != nil {
+			return Hash{}, nil, fmt.Errorf("block must be a number or a hash: %w", err)
+		}
+
+		if number, err = s.blockNumber(hash); err != nil {
+			return Hash{}, nil, err
+		}
+	}
+
+	balances, err := s.BalancesAt(number)
+	if err != nil {
+		return Hash{}, nil, err
+	}
+
+	return s.mainChain[number].hash, balances, nil
+}
+
+func (s *State) blockNumber(hash Hash) (uint64, error) {
+	number, ok := s.mainChainIndex[hash]
+	if !ok {
+		return 0, fmt.Errorf("block '%s' is not in the main chain", hash.Hex())
+	}
+
+	return number, nil
+}
+
+func (s *State) journalEntry(number uint64) (journalEntry, error) {
+	entry, err := s.journal.read(number)
+	if err != nil {
+		return journalEntry{}, err
+	}
+
+	if entry.hash != s.mainChain[number].hash {
+		return journalEntry{}, fmt.Errorf("journal entry of block '%d' doesn't match the main chain", number)
+	}
+
+	return entry, nil
+}
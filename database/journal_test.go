@@ -0,0 +1,52 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBalancesAt(t *testing.T) {
+	state, dataDir := createTestState(t, `{"chain_id": "test-chain", "difficulty": 4, "balances": {"andrej": 1000}}`)
+
+	expected := make([]map[Account]Amount, 0)
+	for _, miner := range []Account{"andrej", "babayaga", "andrej", "caesar", "babayaga"} {
+		mineTestBlock(t, state, miner, nil)
+
+		balances := make(map[Account]Amount)
+		for account, balance := range state.Balances {
+			balances[account] = balance
+		}
+		expected = append(expected, balances)
+	}
+	state.Close()
+
+	state, err := NewStateFromDisk(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+
+	for number, balances := range expected {
+		at, err := state.BalancesAt(uint64(number))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(at, balances) {
+			t.Fatalf("balances at block %d should be %v, not %v", number, balances, at)
+		}
+	}
+
+	hash, balances, err := state.BalancesAtBlock(state.mainChain[1].hash.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hash != state.mainChain[1].hash || !reflect.DeepEqual(balances, expected[1]) {
+		t.Fatal("balances by block hash should match the ones by block number")
+	}
+
+	if _, err := state.BalancesAt(uint64(len(expected))); err == nil {
+		t.Fatal("balances after an unknown block should not be returned")
+	}
+}
@@ -0,0 +1,277 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// legacyRewardData marks the legacy TXs minting the block reward
+const legacyRewardData = "reward"
+
+// LegacyTx is a TX of the ledgers written before TXs were signed,
+// with the block rewards minted by TXs of "reward" data and values in whole TBB
+type LegacyTx struct {
+	From  Account `json:"From"`
+	To    Account `json:"To"`
+	Value uint    `json:"Value"`
+	Data  string  `json:"Data"`
+}
+
+func (t LegacyTx) IsReward() bool {
+	return t.Data == legacyRewardData
+}
+
+// isNoop tells if the TX moves no TBB, such TXs are rejected by the chain
+func (t LegacyTx) isNoop() bool {
+	return !t.IsReward() && (t.From == t.To || t.Value == 0)
+}
+
+type LegacyBlockHeader struct {
+	Parent Hash   `json:"Parent"`
+	Time   uint64 `json:"Time"`
+}
+
+type LegacyBlock struct {
+	Header LegacyBlockHeader `json:"Header"`
+	TXs    []LegacyTx        `json:"Txs"`
+}
+
+// Hash is the SHA-256 of the block JSON, as legacy ledgers computed it
+func (b LegacyBlock) Hash() (Hash, error) {
+	blockJSON, err := json.Marshal(b)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	return sha256.Sum256(blockJSON), nil
+}
+
+// reward returns the account credited by the block reward TXs and their total
+func (b LegacyBlock) reward() (Account, Amount, error) {
+	miner, reward := Account(""), Amount(0)
+
+	for _, tx := range b.TXs {
+		if !tx.IsReward() {
+			continue
+		}
+
+		if miner != "" && tx.To != miner {
+			return "", 0, fmt.Errorf("block rewards both '%s' and '%s'", miner, tx.To)
+		}
+
+		value, err := AmountFromTBB(uint64(tx.Value))
+		if err == nil {
+			reward, err = reward.Add(value)
+		}
+		if err != nil {
+			return "", 0, fmt.Errorf("block reward: %w", err)
+		}
+		miner = tx.To
+	}
+
+	if miner == "" {
+		return "", 0, fmt.Errorf("block has no reward TX")
+	}
+
+	return miner, reward, nil
+}
+
+type LegacyBlockFS struct {
+	Key   Hash        `json:"hash"`
+	Value LegacyBlock `json:"block"`
+}
+
+// LegacyLedger is the content of a legacy tx.db, one JSON block per line
+type LegacyLedger []LegacyBlockFS
+
+func LoadLegacyLedger(path string) (LegacyLedger, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening legacy ledger: %w", err)
+	}
+	defer f.Close()
+
+	ledger := make(LegacyLedger, 0)
+	decoder := json.NewDecoder(f)
+
+	for {
+		var blockFS LegacyBlockFS
+		err := decoder.Decode(&blockFS)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error while decoding legacy block %d: %w", len(ledger), err)
+		}
+
+		ledger = append(ledger, blockFS)
+	}
+
+	return ledger, nil
+}
+
+// Replay checks the ledger hashes and parents and applies its TXs with the
+// legacy rules on top of the genesis balances, returning the final balances.
+// Every transfer must be covered by the sender balance.
+func (l LegacyLedger) Replay(genesisBalances map[Account]Amount) (map[Account]Amount, error) {
+	balances := make(map[Account]Amount)
+	for account, balance := range genesisBalances {
+		balances[account] = balance
+	}
+
+	parent := Hash{}
+	for i, blockFS := range l {
+		hash, err := blockFS.Value.Hash()
+		if err != nil {
+			return nil, err
+		}
+
+		if hash != blockFS.Key {
+			return nil, fmt.Errorf("legacy block %d hash must be '%s' not '%s'", i, hash.Hex(), blockFS.Key.Hex())
+		}
+
+		if blockFS.Value.Header.Parent != parent {
+			return nil, fmt.Errorf("legacy block %d parent must be '%s' not '%s'", i, parent.Hex(), blockFS.Value.Header.Parent.Hex())
+		}
+		parent = hash
+
+		for j, tx := range blockFS.Value.TXs {
+			value, err := AmountFromTBB(uint64(tx.Value))
+			if err != nil {
+				return nil, fmt.Errorf("legacy block %d TX %d: %w", i, j, err)
+			}
+
+			if !tx.IsReward() {
+				if value > balances[tx.From] {
+					return nil, fmt.Errorf("legacy block %d TX %d: sender '%s' balance is %s TBB, less than %s TBB", i, j, tx.From, balances[tx.From], value)
+				}
+				balances[tx.From] -= value
+			}
+
+			if balances[tx.To], err = balances[tx.To].Add(value); err != nil {
+				return nil, fmt.Errorf("legacy block %d TX %d: recipient '%s' balance: %w", i, j, tx.To, err)
+			}
+		}
+	}
+
+	return balances, nil
+}
+
+// BlockReward returns the reward minted by every ledger block. Blocks only
+// reward their miner with the genesis block reward, so ledgers minting
+// different rewards can't be migrated.
+func (l LegacyLedger) BlockReward() (Amount, error) {
+	blockReward := Amount(0)
+
+	for i, blockFS := range l {
+		_, reward, err := blockFS.Value.reward()
+		if err != nil {
+			return 0, fmt.Errorf("legacy block %d: %w", i, err)
+		}
+
+		if i > 0 && reward != blockReward {
+			return 0, fmt.Errorf("legacy block %d reward is %s TBB, not %s TBB as the blocks before it", i, reward, blockReward)
+		}
+		blockReward = reward
+	}
+
+	return blockReward, nil
+}
+
+// MigrateLegacyLedger converts every legacy block into a block of the state
+// chain, with the accounts renamed, the transfers signed by sign and the
+// rewards credited to the block miner, then seals and adds it. The state
+// genesis must be the legacy one migrated the same way. Self and zero-value
+// transfers change no balance and are dropped. The migrated balances are
+// checked against the legacy ones.
+func MigrateLegacyLedger(state *State, ledger LegacyLedger, legacyBalances map[Account]Amount, accounts map[Account]Account, sign func(Tx) (SignedTx, error), seal func(Block) (Block, error)) error {
+	rename := func(account Account) Account {
+		if renamed, ok := accounts[account]; ok {
+			return renamed
+		}
+		return account
+	}
+
+	for i, blockFS := range ledger {
+		legacy := blockFS.Value
+
+		miner, _, err := legacy.reward()
+		if err != nil {
+			return fmt.Errorf("legacy block %d: %w", i, err)
+		}
+
+		txs := make([]SignedTx, 0, len(legacy.TXs))
+		for _, legacyTx := range legacy.TXs {
+			if legacyTx.IsReward() || legacyTx.isNoop() {
+				continue
+			}
+
+			value, err := AmountFromTBB(uint64(legacyTx.Value))
+			if err != nil {
+				return fmt.Errorf("legacy block %d: %w", i, err)
+			}
+
+			from := rename(legacyTx.From)
+			tx := NewTx(from, rename(legacyTx.To), value, 0, state.GetNextAccountNonce(from)+countSentTXs(txs, from), legacyTx.Data)
+
+			signedTx, err := sign(tx)
+			if err != nil {
+				return fmt.Errorf("legacy block %d: error while signing TX of '%s': %w", i, from, err)
+			}
+			txs = append(txs, signedTx)
+		}
+
+		stateRoot, err := state.NextStateRoot(rename(miner), txs)
+		if err != nil {
+			return fmt.Errorf("legacy block %d: %w", i, err)
+		}
+
+		// legacy blocks of the same second are moved just after the median time of the blocks before them
+		blockTime := legacy.Header.Time
+		if minTime := state.NextBlockMinTime(); blockTime < minTime {
+			blockTime = minTime
+		}
+
+		b, err := NewBlock(state.LatestBlockHash(), state.NextBlockNumber(), 0, blockTime, state.NextBlockDifficulty(), stateRoot, rename(miner), txs)
+		if err != nil {
+			return err
+		}
+
+		if b, err = seal(b); err != nil {
+			return err
+		}
+
+		if _, err := state.AddBlock(b); err != nil {
+			return fmt.Errorf("legacy block %d: %w", i, err)
+		}
+	}
+
+	legacyAccounts := make([]Account, 0, len(legacyBalances))
+	for account := range legacyBalances {
+		legacyAccounts = append(legacyAccounts, account)
+	}
+	sort.Slice(legacyAccounts, func(i, j int) bool { return legacyAccounts[i] < legacyAccounts[j] })
+
+	for _, account := range legacyAccounts {
+		if balance := state.Balances[rename(account)]; balance != legacyBalances[account] {
+			return fmt.Errorf("migrated '%s' balance is %s TBB, not %s TBB as in the legacy ledger", rename(account), balance, legacyBalances[account])
+		}
+	}
+
+	return nil
+}
+
+func countSentTXs(txs []SignedTx, from Account) uint {
+	count := uint(0)
+	for _, tx := range txs {
+		if tx.From == from {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func TestMigrateLegacyLedger(t *testing.T) {
+	ledger, err := LoadLegacyLedger("tx.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacyGen, err := LoadGenesis("genesisdb.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacyBalances, err := ledger.Replay(legacyGen.Balances)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[Account]Amount{"andrej": 999451 * TBB, "babayaga": 949 * TBB, "caesar": 1000 * TBB}
+	for account, balance := range expected {
+		if legacyBalances[account] != balance {
+			t.Fatalf("legacy '%s' balance should be %s, not %s", account, balance, legacyBalances[account])
+		}
+	}
+
+	blockReward, err := ledger.BlockReward()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := make(map[Account]*secp256k1.PrivateKey)
+	accounts := make(map[Account]Account)
+	for _, legacy := range []Account{"andrej", "babayaga"} {
+		privKey, err := secp256k1.GeneratePrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		accounts[legacy] = PubKeyToAccount(privKey.PubKey())
+		keys[accounts[legacy]] = privKey
+	}
+
+	genesis := fmt.Sprintf(`{"chain_id": "migrated", "difficulty": %d, "block_reward": "%s", "balances": {"%s": 1000000}}`, testDifficulty, blockReward, accounts["andrej"])
+	gen, err := ParseGenesis([]byte(genesis))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := NewStateFromStore(gen, NewMemoryBlockStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(tx Tx) (SignedTx, error) {
+		privKey, ok := keys[tx.From]
+		if !ok {
+			return SignedTx{}, fmt.Errorf("no key of '%s'", tx.From)
+		}
+		return signTestTx(t, privKey, tx), nil
+	}
+	seal := func(b Block) (Block, error) {
+		for {
+			hash, err := b.Hash()
+			if err != nil {
+				return Block{}, err
+			}
+
+			if !hash.IsEmpty() && IsBlockHashValid(hash, b.Header.Difficulty) {
+				return b, nil
+			}
+			b.Header.Nonce++
+		}
+	}
+
+	if err := MigrateLegacyLedger(state, ledger, legacyBalances, accounts, sign, seal); err != nil {
+		t.Fatal(err)
+	}
+
+	if state.NextBlockNumber() != uint64(len(ledger)) || state.Balances["caesar"] != 1000*TBB {
+		t.Fatal("every legacy block should be migrated")
+	}
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// Leaves and inner nodes are hashed with distinct prefixes,
+// so an inner node can't be passed off as a TX (second preimage)
+const merkleLeafPrefix = 0x00
+const merkleNodePrefix = 0x01
+
+type MerkleProofStep struct {
+	Hash Hash `json:"hash"`
+	// sibling is on the left, hash it before the current node
+	IsLeft bool `json:"is_left"`
+}
+
+// MerkleProof proves a TX is included in the block whose header holds the TxRoot
+type MerkleProof struct {
+	TxHash Hash              `json:"tx_hash"`
+	Index  int               `json:"index"`
+	Path   []MerkleProofStep `json:"path"`
+}
+
+// TxRoot computes the Merkle root of the TXs hashes.
+// A level with an odd number of nodes promotes the last one unchanged.
+// A block without TXs has an empty root.
+func TxRoot(txs []SignedTx) (Hash, error) {
+	levels, err := merkleTree(txs)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	if len(levels) == 0 {
+		return Hash{}, nil
+	}
+
+	return levels[len(levels)-1][0], nil
+}
+
+// TxInclusionProof returns the Merkle path from the TX to the block TxRoot
+func TxInclusionProof(block Block, txHash Hash) (MerkleProof, error) {
+	levels, err := merkleTree(block.TXs)
+	if err != nil {
+		return MerkleProof{}, err
+	}
+
+	index := -1
+	for i, leaf := range block.TXs {
+		hash, err := leaf.Hash()
+		if err != nil {
+			return MerkleProof{}, err
+		}
+
+		if hash == txHash {
+			index = i
+			break
+		}
+	}
+
+	if index < 0 {
+		return MerkleProof{}, fmt.Errorf("TX '%s' is not included in block '%d'", txHash.Hex(), block.Header.Number)
+	}
+
+	proof := MerkleProof{TxHash: txHash, Index: index, Path: make([]MerkleProofStep, 0)}
+
+	pos := index
+	for _, level := range levels[:len(levels)-1] {
+		if pos%2 == 1 {
+			proof.Path = append(proof.Path, MerkleProofStep{level[pos-1], true})
+		} else if pos+1 < len(level) {
+			proof.Path = append(proof.Path, MerkleProofStep{level[pos+1], false})
+		}
+
+		pos /= 2
+	}
+
+	return proof, nil
+}
+
+// Verify recomputes the root from the TX hash and the proof path
+func (p MerkleProof) Verify(txRoot Hash) bool {
+	node := hashMerkleLeaf(p.TxHash)
+
+	for _, step := range p.Path {
+		if step.IsLeft {
+			node = hashMerkleNode(step.Hash, node)
+		} else {
+			node = hashMerkleNode(node, step.Hash)
+		}
+	}
+
+	return node == txRoot
+}
+
+// merkleTree returns all the tree levels, from the leaves to the root
+func merkleTree(txs []SignedTx) ([][]Hash, error) {
+	if len(txs) == 0 {
+		return nil, nil
+	}
+
+	level := make([]Hash, len(txs))
+	for i, tx := range txs {
+		txHash, err := tx.Hash()
+		if err != nil {
+			return nil, err
+		}
+
+		level[i] = hashMerkleLeaf(txHash)
+	}
+
+	levels := [][]Hash{level}
+
+	for len(level) > 1 {
+		next := make([]Hash, 0, (len(level)+1)/2)
+
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+
+			next = append(next, hashMerkleNode(level[i], level[i+1]))
+		}
+
+		levels = append(levels, next)
+		level = next
+	}
+
+	return levels, nil
+}
+
+func hashMerkleLeaf(txHash Hash) Hash {
+	return sha256.Sum256(append([]byte{merkleLeafPrefix}, txHash[:]...))
+}
+
+func hashMerkleNode(left Hash, right Hash) Hash {
+	data := make([]byte, 0, 1+2*len(left))
+	data = append(data, merkleNodePrefix)
+	data = append(data, left[:]...)
+	data = append(data, right[:]...)
+
+	return sha256.Sum256(data)
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestTxInclusionProof(t *testing.T) {
+	for txsCount := 1; txsCount <= 7; txsCount++ {
+		txs := make([]SignedTx, txsCount)
+		for i := range txs {
+			txs[i] = NewSignedTx(NewTx("andrej", "babayaga", Amount(i+1), 0, uint(i+1), ""), nil)
+		}
+
+		block, err := NewBlock(Hash{}, 0, 0, 0, 0, Hash{}, "andrej", txs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, tx := range txs {
+			txHash, err := tx.Hash()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			proof, err := TxInclusionProof(block, txHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !proof.Verify(block.Header.TxRoot) {
+				t.Fatalf("proof of TX %d out of %d should verify against the block TX root", i, txsCount)
+			}
+		}
+	}
+}
+
+func TestTxInclusionProofRejectsOtherTx(t *testing.T) {
+	txs := []SignedTx{
+		NewSignedTx(NewTx("andrej", "babayaga", 1, 0, 1, ""), nil),
+		NewSignedTx(NewTx("andrej", "babayaga", 2, 0, 2, ""), nil),
+	}
+
+	block, err := NewBlock(Hash{}, 0, 0, 0, 0, Hash{}, "andrej", txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txHash, _ := txs[0].Hash()
+	proof, err := TxInclusionProof(block, txHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proof.TxHash, _ = NewSignedTx(NewTx("andrej", "caesar", 1000, 0, 1, ""), nil).Hash()
+	if proof.Verify(block.Header.TxRoot) {
+		t.Fatal("proof of a TX not in the block should not verify")
+	}
+
+	_, err = TxInclusionProof(block, proof.TxHash)
+	if err == nil {
+		t.Fatal("TX not in the block should have no proof")
+	}
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+// MaxMultisigKeys is the maximum number of public keys of a multisig account
+const MaxMultisigKeys = 16
+
+// multisigAddressPrefix separates multisig addresses from the single key ones
+const multisigAddressPrefix = "tbb-multisig"
+
+// Multisig is an M-of-N account: its TXs must be signed by Threshold of its
+// compressed secp256k1 public keys. The chain doesn't register it, its TXs
+// carry it so the account address can be checked against it.
+type Multisig struct {
+	Threshold uint32   `json:"threshold"`
+	PubKeys   [][]byte `json:"pub_keys"`
+}
+
+// NewMultisig sorts the public keys so the account doesn't depend on their order
+func NewMultisig(threshold uint32, pubKeys [][]byte) (Multisig, error) {
+	sorted := make([][]byte, len(pubKeys))
+	copy(sorted, pubKeys)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i], sorted[j]) < 0 })
+
+	m := Multisig{threshold, sorted}
+
+	return m, m.Validate()
+}
+
+func (m Multisig) Validate() error {
+	if len(m.PubKeys) == 0 || len(m.PubKeys) > MaxMultisigKeys {
+		return fmt.Errorf("multisig account must have 1 to %d public keys, not %d", MaxMultisigKeys, len(m.PubKeys))
+	}
+
+	if m.Threshold == 0 || int(m.Threshold) > len(m.PubKeys) {
+		return fmt.Errorf("multisig threshold must be 1 to %d, not %d", len(m.PubKeys), m.Threshold)
+	}
+
+	for i, pubKey := range m.PubKeys {
+		if len(pubKey) != secp256k1.PubKeyBytesLenCompressed {
+			return fmt.Errorf("multisig public key %d must be a %d bytes compressed key", i, secp256k1.PubKeyBytesLenCompressed)
+		}
+
+		if _, err := secp256k1.ParsePubKey(pubKey); err != nil {
+			return fmt.Errorf("multisig public key %d: %w", i, err)
+		}
+
+		if i > 0 && bytes.Compare(m.PubKeys[i-1], pubKey) >= 0 {
+			return fmt.Errorf("multisig public keys must be sorted and distinct")
+		}
+	}
+
+	return nil
+}
+
+// Account is the address of the multisig account, the last 20 bytes of
+// the Keccak-256 hash of its binary encoding. The encoding version is the
+// one multisig accounts came with, so the address never changes.
+func (m Multisig) Account() Account {
+	e := newVersionEncoder(multisigEncodingVersion)
+	e.string(multisigAddressPrefix)
+	m.encode(e)
+
+	keccak := sha3.NewLegacyKeccak256()
+	keccak.Write(e.buf.Bytes())
+
+	return NewAccount("0x" + hex.EncodeToString(keccak.Sum(nil)[12:]))
+}
+
+// signer returns the multisig public key which signed the hash
+func (m Multisig) signer(hash Hash, sig []byte) ([]byte, error) {
+	pubKey, _, err := ecdsa.RecoverCompact(sig, hash[:])
+	if err != nil {
+		return nil, fmt.Errorf("error while recovering public key from TX signature: %w", err)
+	}
+
+	signer := pubKey.SerializeCompressed()
+	for _, member := range m.PubKeys {
+		if bytes.Equal(member, signer) {
+			return signer, nil
+		}
+	}
+
+	return nil, fmt.Errorf("TX is signed by '%x', not a key of the multisig account", signer)
+}
+
+func (m Multisig) encode(e *encoder) {
+	e.uint32(m.Threshold)
+	e.length(len(m.PubKeys))
+	for _, pubKey := range m.PubKeys {
+		e.bytes(pubKey)
+	}
+}
+
+func (m *Multisig) decode(d *decoder) {
+	m.Threshold = d.uint32()
+	m.PubKeys = make([][]byte, d.length())
+	for i := range m.PubKeys {
+		m.PubKeys[i] = d.bytes()
+	}
+}
+
+// NewMultisigTx returns the TX of a multisig account without signatures yet
+func NewMultisigTx(tx Tx, m Multisig) SignedTx {
+	return SignedTx{Tx: tx, Multisig: &m}
+}
+
+// AddMultisigSig adds the signature of one of the multisig keys, replacing
+// a previous signature of the same key
+func (t *SignedTx) AddMultisigSig(sig []byte) error {
+	if t.Multisig == nil {
+		return fmt.Errorf("TX from '%s' is not a multisig TX", t.From)
+	}
+
+	txHash, err := t.Tx.Hash()
+	if err != nil {
+		return err
+	}
+
+	signer, err := t.Multisig.signer(txHash, sig)
+	if err != nil {
+		return err
+	}
+
+	for i, other := range t.Sigs {
+		if otherSigner, err := t.Multisig.signer(txHash, other); err == nil && bytes.Equal(otherSigner, signer) {
+			t.Sigs[i] = sig
+			return nil
+		}
+	}
+	t.Sigs = append(t.Sigs, sig)
+
+	return nil
+}
+
+// CombineMultisigTXs merges the signatures gathered separately for the same multisig TX
+func CombineMultisigTXs(txs []SignedTx) (SignedTx, error) {
+	if len(txs) == 0 {
+		return SignedTx{}, fmt.Errorf("no multisig TX to combine")
+	}
+
+	combined := txs[0]
+	combined.Sigs = nil
+
+	txHash, err := combined.Tx.Hash()
+	if err != nil {
+		return SignedTx{}, err
+	}
+
+	for i, tx := range txs {
+		otherHash, err := tx.Tx.Hash()
+		if err != nil {
+			return SignedTx{}, err
+		}
+
+		if otherHash != txHash || tx.Multisig == nil || combined.Multisig == nil || tx.Multisig.Account() != combined.Multisig.Account() {
+			return SignedTx{}, fmt.Errorf("multisig TX %d is not the same TX as the first one", i)
+		}
+
+		for _, sig := range tx.Sigs {
+			if err := combined.AddMultisigSig(sig); err != nil {
+				return SignedTx{}, fmt.Errorf("multisig TX %d: %w", i, err)
+			}
+		}
+	}
+
+	return combined, nil
+}
+
+// checkMultisig checks the TX is sent by its multisig account and signed
+// by at least the threshold of distinct account keys
+func (t SignedTx) checkMultisig(txHash Hash) error {
+	if err := t.Multisig.Validate(); err != nil {
+		return err
+	}
+
+	if account := t.Multisig.Account(); account != t.From {
+		return fmt.Errorf("TX sender '%s' is not the multisig account '%s'", t.From, account)
+	}
+
+	if len(t.Sig) > 0 {
+		return fmt.Errorf("multisig TX from '%s' must only carry the signatures of the account keys", t.From)
+	}
+
+	signers := make(map[string]bool)
+	for i, sig := range t.Sigs {
+		signer, err := t.Multisig.signer(txHash, sig)
+		if err != nil {
+			return fmt.Errorf("multisig TX signature %d: %w", i, err)
+		}
+
+		if signers[string(signer)] {
+			return fmt.Errorf("multisig TX is signed twice by '%x'", signer)
+		}
+		signers[string(signer)] = true
+	}
+
+	if len(signers) < int(t.Multisig.Threshold) {
+		return fmt.Errorf("multisig TX from '%s' has %d of the %d signatures required", t.From, len(signers), t.Multisig.Threshold)
+	}
+
+	return nil
+}
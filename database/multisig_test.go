@@ -0,0 +1,142 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+func TestMultisigTxRequiresThreshold(t *testing.T) {
+	keys := make([]*secp256k1.PrivateKey, 4)
+	pubKeys := make([][]byte, 3)
+	for i := range keys {
+		privKey, err := secp256k1.GeneratePrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = privKey
+
+		if i < len(pubKeys) {
+			pubKeys[i] = privKey.PubKey().SerializeCompressed()
+		}
+	}
+
+	multisig, err := NewMultisig(2, pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reversed, err := NewMultisig(2, [][]byte{pubKeys[2], pubKeys[1], pubKeys[0]})
+	if err != nil || reversed.Account() != multisig.Account() {
+		t.Fatalf("multisig account should not depend on the keys order, got %v", err)
+	}
+
+	if _, err := NewMultisig(4, pubKeys); err == nil {
+		t.Fatal("threshold above the number of keys should be rejected")
+	}
+
+	treasury := multisig.Account()
+	state, _ := createTestState(t, fmt.Sprintf(`{"chain_id": "test-chain", "difficulty": %d, "balances": {"%s": 1000}}`, testDifficulty, treasury))
+	defer state.Close()
+
+	tx := NewMultisigTx(NewTx(treasury, "babayaga", 100*TBB, 0, 1, ""), multisig)
+	txHash, err := tx.Tx.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(tx SignedTx, privKey *secp256k1.PrivateKey) (SignedTx, error) {
+		tx.Sigs = append([][]byte(nil), tx.Sigs...)
+		err := tx.AddMultisigSig(ecdsa.SignCompact(privKey, txHash[:], false))
+		return tx, err
+	}
+
+	if _, err := sign(tx, keys[3]); err == nil {
+		t.Fatal("signature of a key outside the multisig account should be rejected")
+	}
+
+	first, err := sign(tx, keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := state.NextStateRoot("andrej", []SignedTx{first}); err == nil || !strings.Contains(err.Error(), "1 of the 2 signatures") {
+		t.Fatalf("TX with a single signature should be rejected, got %v", err)
+	}
+
+	// the key holders sign their own copies, and sign again without adding a signature
+	second, err := sign(tx, keys[2])
+	if err == nil {
+		second, err = sign(second, keys[2])
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	combined, err := CombineMultisigTXs([]SignedTx{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(combined.Sigs) != 2 {
+		t.Fatalf("combined TX should have 2 signatures, got %d", len(combined.Sigs))
+	}
+
+	combinedJSON, err := json.Marshal(combined)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded SignedTx
+	if err := json.Unmarshal(combinedJSON, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	combinedBin, err := decoded.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded = SignedTx{}
+	if err := decoded.UnmarshalBinary(combinedBin); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded, combined) {
+		t.Fatalf("multisig TX should survive the JSON and binary encodings, got %+v", decoded)
+	}
+
+	mineTestBlock(t, state, "andrej", []SignedTx{decoded})
+	if state.Balances["babayaga"] != 100*TBB || state.Balances[treasury] != 900*TBB {
+		t.Fatalf("multisig TX should be applied, got balances %v", state.Balances)
+	}
+
+	// a single key TX can't spend from the multisig account
+	forged := signTestTx(t, keys[0], NewTx(treasury, "babayaga", TBB, 0, 2, ""))
+	if _, err := state.NextStateRoot("andrej", []SignedTx{forged}); err == nil {
+		t.Fatal("single key TX of the multisig account should be rejected")
+	}
+}
+
+func TestSingleKeyTxRejectsMultisigSignatures(t *testing.T) {
+	privKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc := PubKeyToAccount(privKey.PubKey())
+
+	tx := signTestTx(t, privKey, NewTx(acc, "babayaga", TBB, 0, 1, ""))
+	if ok, err := tx.IsAuthentic(); err != nil || !ok {
+		t.Fatalf("TX signed by its sender should be authentic, got %v", err)
+	}
+
+	// a relay appending junk signatures would change the TX hash
+	tx.Sigs = [][]byte{[]byte("junk")}
+	if ok, err := tx.IsAuthentic(); err == nil || ok {
+		t.Fatal("single key TX carrying multisig signatures should be rejected")
+	}
+}
@@ -0,0 +1,187 @@
+package database
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SnapshotInterval is the number of blocks between two balance snapshots
+const SnapshotInterval = 100
+
+// number of most recent snapshots kept on disk, older ones being removed
+const keptSnapshots = 3
+
+const snapshotFileExt = ".snap"
+
+// snapshot holds the balances and nonces right after the block of the number and hash
+type snapshot struct {
+	number        uint64
+	hash          Hash
+	balances      map[Account]Amount
+	account2Nonce map[Account]uint
+}
+
+func (snap snapshot) fileName() string {
+	// zero padded so the file names sort by block number
+	return fmt.Sprintf("%020d-%s%s", snap.number, snap.hash.Hex(), snapshotFileExt)
+}
+
+func (snap snapshot) MarshalBinary() ([]byte, error) {
+	e := newEncoder()
+	e.uint64(snap.number)
+	e.hash(snap.hash)
+
+	accounts := make([]string, 0, len(snap.balances))
+	for account := range snap.balances {
+		accounts = append(accounts, string(account))
+	}
+	for account := range snap.account2Nonce {
+		if _, ok := snap.balances[account]; !ok {
+			accounts = append(accounts, string(account))
+		}
+	}
+	sort.Strings(accounts)
+
+	e.length(len(accounts))
+	for _, account := range accounts {
+		e.string(account)
+		e.uint64(uint64(snap.balances[Account(account)]))
+		e.uint64(uint64(snap.account2Nonce[Account(account)]))
+	}
+
+	return e.buf.Bytes(), nil
+}
+
+func (snap *snapshot) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	snap.number = d.uint64()
+	snap.hash = d.hash()
+
+	snap.balances = make(map[Account]Amount)
+	snap.account2Nonce = make(map[Account]uint)
+
+	n := d.length()
+	for i := 0; i < n && d.err == nil; i++ {
+		account := Account(d.string())
+		snap.balances[account] = Amount(d.uint64())
+
+		if nonce := uint(d.uint64()); nonce > 0 {
+			snap.account2Nonce[account] = nonce
+		}
+	}
+
+	return d.finish()
+}
+
+// stateRoot is the state root of the snapshot balances, to check it against the block header's
+func (snap snapshot) stateRoot() Hash {
+	s := &State{Balances: snap.balances, Account2Nonce: snap.account2Nonce}
+	return s.StateRoot()
+}
+
+// writeSnapshot persists the state as of its latest block and removes old snapshots
+func (s *State) writeSnapshot() error {
+	if err := os.MkdirAll(s.snapshotDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	snap := snapshot{s.latestBlock.Header.Number, s.latestBlockHash, s.Balances, s.Account2Nonce}
+	snapBin, err := snap.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	// written aside first so a crash never leaves a partial snapshot behind
+	path := filepath.Join(s.snapshotDir, snap.fileName())
+	if err := ioutil.WriteFile(path+".tmp", snapBin, 0600); err != nil {
+		return err
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	fmt.Printf("Saved balances snapshot of block '%s' at height %d\n", snap.hash.Hex(), snap.number)
+
+	paths := listSnapshotFiles(s.snapshotDir)
+	for i := keptSnapshots; i < len(paths); i++ {
+		_ = os.Remove(paths[i])
+	}
+
+	return nil
+}
+
+// maybeWriteSnapshot snapshots the state every SnapshotInterval blocks
+func (s *State) maybeWriteSnapshot() {
+	if s.snapshotDir == "" || s.latestBlock.Header.Number == 0 || s.latestBlock.Header.Number%SnapshotInterval != 0 {
+		return
+	}
+
+	if err := s.writeSnapshot(); err != nil {
+		fmt.Printf("WARNING: error while writing balances snapshot: %s\n", err)
+	}
+}
+
+// listSnapshotFiles returns the snapshot file paths, newest first
+func listSnapshotFiles(dir string) []string {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	paths := make([]string, 0)
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), snapshotFileExt) {
+			paths = append(paths, filepath.Join(dir, f.Name()))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+
+	return paths
+}
+
+// findValidSnapshot returns the newest snapshot of a stored and journaled
+// block whose balances match the block's state root
+func findValidSnapshot(dir string, store BlockStore, journal *balanceJournal) (snapshot, bool) {
+	for _, path := range listSnapshotFiles(dir) {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		var snap snapshot
+		if err := snap.UnmarshalBinary(content); err != nil {
+			fmt.Printf("WARNING: ignoring unreadable balances snapshot %s: %s\n", path, err)
+			continue
+		}
+
+		var header BlockHeader
+		var hash Hash
+		err = store.Iterate(snap.number, snap.number, func(h Hash, b Block) error {
+			hash = h
+			header = b.Header
+			return nil
+		})
+		if err != nil || hash != snap.hash {
+			continue
+		}
+
+		// the undo records of the blocks the snapshot covers come from the journal
+		if journaled, _ := journal.hashAt(snap.number); journaled != snap.hash {
+			continue
+		}
+
+		if header.StateRoot != snap.stateRoot() {
+			fmt.Printf("WARNING: ignoring balances snapshot %s not matching its block state root\n", path)
+			continue
+		}
+
+		return snap, true
+	}
+
+	return snapshot{}, false
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestStartupLoadsValidSnapshot(t *testing.T) {
+	state, dataDir := createTestState(t, `{"chain_id": "test-chain", "difficulty": 4, "balances": {"andrej": 1000}}`)
+	mineTestBlock(t, state, "andrej", nil)
+	mineTestBlock(t, state, "babayaga", nil)
+	if err := state.writeSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	mineTestBlock(t, state, "caesar", nil)
+	expectedRoot := state.StateRoot()
+	state.Close()
+
+	state, err := NewStateFromDisk(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !state.mainChain[1].undo.isMissing() || state.mainChain[2].undo.isMissing() {
+		t.Fatal("only the blocks after the snapshot should be replayed")
+	}
+
+	if state.StateRoot() != expectedRoot || state.NextBlockNumber() != 3 {
+		t.Fatal("state loaded from the snapshot should match the replayed one")
+	}
+	state.Close()
+
+	// a snapshot not matching its block state root is ignored
+	paths := listSnapshotFiles(getSnapshotDirPath(dataDir))
+	if len(paths) != 1 {
+		t.Fatalf("expected 1 snapshot, got %d", len(paths))
+	}
+
+	var snap snapshot
+	content, err := ioutil.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := snap.UnmarshalBinary(content); err != nil {
+		t.Fatal(err)
+	}
+
+	snap.balances["andrej"] += 1
+	forged, err := snap.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(getSnapshotDirPath(dataDir), snap.fileName()), forged, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = NewStateFromDisk(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+
+	if state.mainChain[0].undo.isMissing() || state.StateRoot() != expectedRoot {
+		t.Fatal("a forged snapshot should fall back to a full replay")
+	}
+}
+
+func TestReorgBelowSnapshot(t *testing.T) {
+	genesis := `{"chain_id": "test-chain", "difficulty": 4, "balances": {}}`
+
+	state, dataDir := createTestState(t, genesis)
+	mineTestBlock(t, state, "andrej", nil)
+	mineTestBlock(t, state, "andrej", nil)
+	if err := state.writeSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	state.Close()
+
+	state, err := NewStateFromDisk(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+
+	gen, err := ParseGenesis([]byte(genesis))
+	if err != nil {
+		t.Fatal(err)
+	}
+	branchState, err := NewStateFromStore(gen, NewMemoryBlockStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		b := mineTestBlock(t, branchState, "caesar", nil)
+		if _, err := state.AddBlock(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if state.LatestBlockHash() != branchState.LatestBlockHash() || state.Balances["andrej"] != 0 {
+		t.Fatal("heavier branch forking below the snapshot should replace the main chain")
+	}
+}
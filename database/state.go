@@ -1,25 +1,40 @@
 package database
 
 import (
-	"bufio"
-	"encoding/json"
 	"fmt"
-	"os"
-	"reflect"
+	"math"
 )
 
 type State struct {
-	Balances map[Account]uint
+	Balances      map[Account]Amount
+	Account2Nonce map[Account]uint
 
-	dbFile *os.File
+	store   BlockStore
+	journal *balanceJournal
+	indexes chainIndexes
+	genesis Genesis
+	// balances snapshots are only written when set
+	snapshotDir string
 
 	latestBlock     Block
 	latestBlockHash Hash
 	hasGenesisBlock bool
+
+	// time of the first block of the current difficulty retarget interval
+	retargetStartTime uint64
+	// times of the latest blocks, the next block must be later than their median
+	recentBlockTimes []uint64
+
+	// the heaviest known chain, persisted in the block store, indexed by block number
+	mainChain      []chainBlock
+	mainChainIndex map[Hash]uint64
+	// blocks of lighter competing branches
+	sideBlocks  map[Hash]sideBlock
+	orphanedTXs []SignedTx
 }
 
 func NewStateFromDisk(dataDir string) (*State, error) {
-	if err := initDataDirIfNotExists(dataDir); err != nil {
+	if err := InitDataDirIfNotExists(dataDir, []byte(genesisJSON)); err != nil {
 		return nil, err
 	}
 
@@ -28,55 +43,118 @@ func NewStateFromDisk(dataDir string) (*State, error) {
 		return nil, err
 	}
 
-	balances := make(map[Account]uint)
-
-	for account, balance := range gen.Balances {
-		balances[account] = balance
+	store, err := NewFileBlockStore(getDatabaseDirPath(dataDir))
+	if err != nil {
+		return nil, err
 	}
 
-	dbFilePath := getBlockDBFilePath(dataDir)
+	journal, err := openBalanceJournal(getJournalFilePath(dataDir))
+	if err != nil {
+		return nil, err
+	}
 
-	f, err := os.OpenFile(dbFilePath, os.O_APPEND|os.O_RDWR, 0600)
+	indexes, err := openChainIndexes(dataDir, gen)
 	if err != nil {
 		return nil, err
 	}
 
+	return newState(gen, store, journal, indexes, getSnapshotDirPath(dataDir))
+}
+
+// NewStateFromStore computes the balances by applying all the stored blocks on top of the genesis
+func NewStateFromStore(gen Genesis, store BlockStore) (*State, error) {
+	return newState(gen, store, newMemoryBalanceJournal(), newMemoryChainIndexes(gen), "")
+}
+
+// newState loads the newest valid balances snapshot of the snapshot dir, if any,
+// and only applies the blocks after it. The blocks the snapshot covers are
+// trusted and only read to index the main chain. The journal and the chain
+// indexes get the entries of the blocks they miss.
+func newState(gen Genesis, store BlockStore, journal *balanceJournal, indexes chainIndexes, snapshotDir string) (*State, error) {
+	balances := make(map[Account]Amount)
+
+	for account, balance := range gen.Balances {
+		balances[account] = balance
+	}
+
 	state := &State{
-		Balances: balances,
+		Balances:      balances,
+		Account2Nonce: make(map[Account]uint),
 
-		dbFile:          f,
+		store:           store,
+		journal:         journal,
+		indexes:         indexes,
+		genesis:         gen,
 		latestBlock:     Block{},
 		latestBlockHash: Hash{},
 		hasGenesisBlock: false,
+
+		snapshotDir:    snapshotDir,
+		mainChainIndex: make(map[Hash]uint64),
+		sideBlocks:     make(map[Hash]sideBlock),
 	}
 
-	scanner := bufio.NewScanner(f)
+	snap, hasSnapshot := snapshot{}, false
+	if snapshotDir != "" {
+		snap, hasSnapshot = findValidSnapshot(snapshotDir, store, journal)
+	}
+
+	if hasSnapshot {
+		fmt.Printf("Loading balances snapshot of block '%s' at height %d\n", snap.hash.Hex(), snap.number)
+	}
 
-	for scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			return nil, fmt.Errorf("error while scanning block.db file: %w", err)
+	err := store.Iterate(0, math.MaxUint64, func(hash Hash, b Block) error {
+		if err := indexes.reconcile(b, hash); err != nil {
+			return err
 		}
 
-		blockJSON := scanner.Bytes()
+		if hasSnapshot && b.Header.Number <= snap.number {
+			if b.Header.Number%gen.RetargetInterval == 0 {
+				state.retargetStartTime = b.Header.Time
+			}
+			state.pushBlockTime(b.Header.Time)
 
-		if len(blockJSON) == 0 {
-			break
+			// without undo record, loaded on demand by a reorg below the snapshot
+			state.pushMainChainBlock(b, hash, blockUndo{})
+
+			if b.Header.Number == snap.number {
+				state.Balances = snap.balances
+				state.Account2Nonce = snap.account2Nonce
+			}
+
+			return nil
 		}
 
-		var blockFS BlockFS
-		err = json.Unmarshal(blockJSON, &blockFS)
-		if err != nil {
-			return nil, fmt.Errorf("error while unmarshalling JSON to blockFS struct: %w", err)
+		undo := newBlockUndo(b, state)
+		if err := applyBlock(b, state); err != nil {
+			return fmt.Errorf("error while calculating balances: %w", err)
 		}
 
-		err = applyBlock(blockFS.Value, state)
-		if err != nil {
-			return nil, fmt.Errorf("error while calculating balances: %w", err)
+		if journaled, _ := journal.hashAt(b.Header.Number); journaled != hash {
+			if err := journal.truncate(b.Header.Number); err != nil {
+				return err
+			}
+
+			if err := journal.append(newJournalEntry(b, hash, undo, state)); err != nil {
+				return err
+			}
 		}
 
-		state.latestBlock = blockFS.Value
-		state.latestBlockHash = blockFS.Key
-		state.hasGenesisBlock = true
+		state.pushMainChainBlock(b, hash, undo)
+		state.maybeWriteSnapshot()
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := journal.truncate(uint64(len(state.mainChain))); err != nil {
+		return nil, err
+	}
+
+	if err := indexes.truncate(uint64(len(state.mainChain))); err != nil {
+		return nil, err
 	}
 
 	return state, nil
@@ -93,41 +171,47 @@ func (s *State) AddBlocks(blocks []Block) error {
 	return nil
 }
 
+// AddBlock appends the block to the main chain if it extends it,
+// otherwise stores it as a side branch block, reorganising the chain
+// if the branch becomes the one with the most cumulative work.
+// Adding an already known block is a no-op.
 func (s *State) AddBlock(b Block) (Hash, error) {
-	pendingState := s.copy()
-	err := applyBlock(b, &pendingState)
+	blockHash, err := b.Hash()
 	if err != nil {
 		return Hash{}, err
 	}
 
-	// block is verfied and ready to be added to blockchain
-	blockHash, err := b.Hash()
-	if err != nil {
-		return Hash{}, err
+	if s.IsKnownBlock(blockHash) {
+		return blockHash, nil
 	}
 
-	blockFS := BlockFS{
-		Key:   blockHash,
-		Value: b,
+	if s.hasGenesisBlock && b.Header.Parent != s.latestBlockHash {
+		return blockHash, s.addSideBlock(b, blockHash)
 	}
 
-	blockFSJSON, err := json.Marshal(blockFS)
+	pendingState := s.copy()
+	undo := newBlockUndo(b, &pendingState)
+	err = applyBlock(b, &pendingState)
 	if err != nil {
 		return Hash{}, err
 	}
 
-	fmt.Println("Persisting new Block to disk:")
-	fmt.Printf("%s\n", blockFSJSON)
-
-	_, err = s.dbFile.Write(append(blockFSJSON, '\n'))
+	// block is verfied and ready to be added to blockchain
+	err = s.store.Append(blockHash, b)
 	if err != nil {
 		return Hash{}, err
 	}
 
+	s.journalBlock(newJournalEntry(b, blockHash, undo, &pendingState))
+	s.indexBlock(b, blockHash)
+
 	s.Balances = pendingState.Balances
-	s.latestBlockHash = blockHash
-	s.latestBlock = b
-	s.hasGenesisBlock = true
+	s.Account2Nonce = pendingState.Account2Nonce
+	s.retargetStartTime = pendingState.retargetStartTime
+	s.recentBlockTimes = pendingState.recentBlockTimes
+	s.pushMainChainBlock(b, blockHash, undo)
+	s.pruneSideBlocks()
+	s.maybeWriteSnapshot()
 
 	return blockHash, nil
 }
@@ -135,22 +219,56 @@ func (s *State) AddBlock(b Block) (Hash, error) {
 // applyBlock verfies if block can be added to blockchain
 // Block meta data are verfied as well as transactions within (sufficient balances) etc
 func applyBlock(b Block, s *State) error {
+	if version := encodingVersionOf(b.Header.encodingVersion); version < amountsEncodingVersion {
+		return fmt.Errorf("block '%d' is encoded with version %d counting whole TBB, its state root can't be checked anymore. Its chain must be rebuilt from the genesis by nodes of encoding version %d", b.Header.Number, version, EncodingVersion)
+	}
+
 	nextExpectedBlockNumber := s.latestBlock.Header.Number + 1
 
 	if s.hasGenesisBlock && b.Header.Number != nextExpectedBlockNumber {
 		return fmt.Errorf("next expected block must be '%d' not '%d'", nextExpectedBlockNumber, b.Header.Number)
 	}
 
-	if s.hasGenesisBlock && s.latestBlock.Header.Number > 0 && !reflect.DeepEqual(b.Header.Parent, s.latestBlockHash) {
+	if !s.hasGenesisBlock && b.Header.Number != 0 {
+		return fmt.Errorf("first block must be '0' not '%d'", b.Header.Number)
+	}
+
+	if b.Header.Parent != s.latestBlockHash {
 		return fmt.Errorf("next block parent hash must be '%x' not '%x'", s.latestBlockHash, b.Header.Parent)
 	}
 
+	if !s.genesis.Time.IsZero() && b.Header.Time < uint64(s.genesis.Time.Unix()) {
+		return fmt.Errorf("block time '%d' is before the genesis time '%d'", b.Header.Time, s.genesis.Time.Unix())
+	}
+
+	if s.hasGenesisBlock && b.Header.Time <= s.MedianTimePast() {
+		return fmt.Errorf("block time '%d' must be later than the median time '%d' of the latest blocks", b.Header.Time, s.MedianTimePast())
+	}
+
+	if err := checkBlockLimits(b, s.genesis); err != nil {
+		return err
+	}
+
+	expectedDifficulty := s.NextBlockDifficulty()
+	if b.Header.Difficulty != expectedDifficulty {
+		return fmt.Errorf("next block difficulty must be '%d' not '%d'", expectedDifficulty, b.Header.Difficulty)
+	}
+
+	txRoot, err := TxRoot(b.TXs)
+	if err != nil {
+		return err
+	}
+
+	if b.Header.TxRoot != txRoot {
+		return fmt.Errorf("block TX root must be '%x' not '%x'", txRoot, b.Header.TxRoot)
+	}
+
 	hash, err := b.Hash()
 	if err != nil {
 		return err
 	}
 
-	if !IsBlockHashValid(hash) {
+	if !IsBlockHashValid(hash, b.Header.Difficulty) {
 		return fmt.Errorf("invalid block %x", hash)
 	}
 
@@ -159,12 +277,43 @@ func applyBlock(b Block, s *State) error {
 		return err
 	}
 
-	s.Balances[b.Header.Miner] += BlockReward
+	if err := applyBlockRewards(b, s); err != nil {
+		return err
+	}
+
+	stateRoot := s.StateRoot()
+	if b.Header.StateRoot != stateRoot {
+		return fmt.Errorf("block state root must be '%x' not '%x'", stateRoot, b.Header.StateRoot)
+	}
+
+	if b.Header.Number%s.genesis.RetargetInterval == 0 {
+		s.retargetStartTime = b.Header.Time
+	}
+	s.pushBlockTime(b.Header.Time)
+
+	return nil
+}
+
+// applyBlockRewards credits the miner with the block reward of the halving schedule and the TXs fees
+func applyBlockRewards(b Block, s *State) error {
+	fees, err := b.FeesReward()
+	if err != nil {
+		return fmt.Errorf("block fees: %w", err)
+	}
+
+	reward, err := s.genesis.BlockRewardAt(b.Header.Number).Add(fees)
+	if err == nil {
+		reward, err = s.Balances[b.Header.Miner].Add(reward)
+	}
+	if err != nil {
+		return fmt.Errorf("miner '%s' balance: %w", b.Header.Miner, err)
+	}
+	s.Balances[b.Header.Miner] = reward
 
 	return nil
 }
 
-func applyTXs(txs []Tx, s *State) error {
+func applyTXs(txs []SignedTx, s *State) error {
 	for _, tx := range txs {
 		if err := applyTx(tx, s); err != nil {
 			return err
@@ -173,28 +322,83 @@ func applyTXs(txs []Tx, s *State) error {
 	return nil
 }
 
-func applyTx(tx Tx, state *State) error {
-	if tx.Value > state.Balances[tx.From] {
-		return fmt.Errorf("invalid transaction. Sender '%s' balance is %d TBB. Tx cost is %d TBB", tx.From, state.Balances[tx.From], tx.Value)
+func applyTx(tx SignedTx, state *State) error {
+	ok, err := tx.IsAuthentic()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("invalid transaction. Sender '%s' signature is forged", tx.From)
 	}
 
-	state.Balances[tx.From] = state.Balances[tx.From] - tx.Value
-	state.Balances[tx.To] = state.Balances[tx.To] + tx.Value
+	if err := tx.Validate(); err != nil {
+		return fmt.Errorf("invalid transaction. %w", err)
+	}
+
+	if state.IsTxLocked(tx.Tx) {
+		return fmt.Errorf("invalid transaction. TX from '%s' is locked until block '%d' and median time '%d'", tx.From, tx.LockHeight, tx.LockTime)
+	}
+
+	expectedNonce := state.GetNextAccountNonce(tx.From)
+	if tx.Nonce != expectedNonce {
+		return fmt.Errorf("invalid transaction. Sender '%s' next nonce must be '%d', not '%d'", tx.From, expectedNonce, tx.Nonce)
+	}
+
+	// Validate checked the cost doesn't overflow
+	cost, _ := tx.Cost()
+	if cost > state.Balances[tx.From] {
+		return fmt.Errorf("invalid transaction. Sender '%s' balance is %s TBB. Tx cost is %s TBB", tx.From, state.Balances[tx.From], cost)
+	}
+
+	received, err := state.Balances[tx.To].Add(tx.Value)
+	if err != nil {
+		return fmt.Errorf("invalid transaction. Recipient '%s' balance: %w", tx.To, err)
+	}
+
+	state.Balances[tx.From] = state.Balances[tx.From] - cost
+	state.Balances[tx.To] = received
+
+	state.Account2Nonce[tx.From] = tx.Nonce
 
 	return nil
 }
 
+// PendingState is a copy of the State the TXs of the next block are tried against
+type PendingState struct {
+	state State
+}
+
+// NewPendingState starts the next block on top of the latest one
+func (s *State) NewPendingState() *PendingState {
+	return &PendingState{s.copy()}
+}
+
+// ApplyTx applies the TX if the next block can include it after the TXs
+// applied before, and leaves the pending state unchanged otherwise
+func (p *PendingState) ApplyTx(tx SignedTx) error {
+	return applyTx(tx, &p.state)
+}
+
 func (s *State) copy() State {
 	c := State{}
 	c.hasGenesisBlock = s.hasGenesisBlock
+	c.genesis = s.genesis
+	c.retargetStartTime = s.retargetStartTime
+	c.recentBlockTimes = append([]uint64(nil), s.recentBlockTimes...)
 	c.latestBlock = s.latestBlock
 	c.latestBlockHash = s.latestBlockHash
-	c.Balances = make(map[Account]uint)
+	c.Balances = make(map[Account]Amount)
+	c.Account2Nonce = make(map[Account]uint)
 
 	for acc, balance := range s.Balances {
 		c.Balances[acc] = balance
 	}
 
+	for acc, nonce := range s.Account2Nonce {
+		c.Account2Nonce[acc] = nonce
+	}
+
 	return c
 }
 
@@ -206,6 +410,16 @@ func (s *State) NextBlockNumber() uint64 {
 	return s.LatestBlock().Header.Number + 1
 }
 
+// GetNextAccountNonce returns the nonce the account's next TX must carry.
+// Nonces start at 1 and increase by exactly 1 per applied TX.
+func (s *State) GetNextAccountNonce(account Account) uint {
+	return s.Account2Nonce[account] + 1
+}
+
+func (s *State) Genesis() Genesis {
+	return s.genesis
+}
+
 func (s *State) LatestBlock() Block {
 	return s.latestBlock
 }
@@ -214,6 +428,22 @@ func (s *State) LatestBlockHash() Hash {
 	return s.latestBlockHash
 }
 
+// journalBlock records the balance changes of the new main chain block.
+// The journal can be rebuilt by replaying the blocks, so a failed write is only reported.
+func (s *State) journalBlock(entry journalEntry) {
+	if err := s.journal.append(entry); err != nil {
+		fmt.Printf("WARNING: %s, balances before block '%d' can't be queried until restart\n", err, entry.number)
+	}
+}
+
 func (s *State) Close() error {
-	return s.dbFile.Close()
+	if err := s.journal.close(); err != nil {
+		return err
+	}
+
+	if err := s.indexes.close(); err != nil {
+		return err
+	}
+
+	return s.store.Close()
 }
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// The state root is the root of a sparse Merkle tree keyed by sha256(account)
+// committing to every account's balance and nonce.
+//
+// To keep it cheap a subtree holding a single account collapses into that
+// account's leaf, and an empty subtree hashes to an empty Hash.
+// Accounts with zero balance and zero nonce are not part of the tree.
+const stateLeafPrefix = 0x00
+const stateNodePrefix = 0x01
+
+type stateLeaf struct {
+	account Account
+	key     Hash
+	hash    Hash
+}
+
+// BalanceProof proves an account's balance and nonce against a block StateRoot.
+// An account absent from the state is proven with a zero balance and a path
+// ending either in an empty subtree or in the leaf of another account.
+type BalanceProof struct {
+	Account Account `json:"account"`
+	Balance Amount  `json:"balance"`
+	Nonce   uint    `json:"nonce"`
+
+	// sibling hashes from the root down to the account's leaf
+	Siblings []Hash `json:"siblings"`
+
+	HasNeighbour       bool `json:"has_neighbour"`
+	NeighbourKey       Hash `json:"neighbour_key"`
+	NeighbourValueHash Hash `json:"neighbour_value_hash"`
+}
+
+// StateRoot computes the sparse Merkle tree root of the current balances and nonces
+func (s *State) StateRoot() Hash {
+	return smtRoot(s.stateLeaves(), 0)
+}
+
+// NextStateRoot returns the state root after the miner's block with the TXs is applied
+func (s *State) NextStateRoot(miner Account, txs []SignedTx) (Hash, error) {
+	pendingState := s.copy()
+	block := Block{Header: BlockHeader{Number: s.NextBlockNumber(), Miner: miner}, TXs: txs}
+
+	err := applyTXs(block.TXs, &pendingState)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	if err := applyBlockRewards(block, &pendingState); err != nil {
+		return Hash{}, err
+	}
+
+	return pendingState.StateRoot(), nil
+}
+
+// BalanceProof returns the account's balance and nonce with their proof against StateRoot
+func (s *State) BalanceProof(account Account) BalanceProof {
+	proof := BalanceProof{
+		Account:  account,
+		Balance:  s.Balances[account],
+		Nonce:    s.Account2Nonce[account],
+		Siblings: make([]Hash, 0),
+	}
+
+	key := stateKey(account)
+	leaves := s.stateLeaves()
+
+	for depth := 0; len(leaves) > 1; depth++ {
+		left, right := splitStateLeaves(leaves, depth)
+
+		if keyBit(key, depth) == 0 {
+			proof.Siblings = append(proof.Siblings, smtRoot(right, depth+1))
+			leaves = left
+		} else {
+			proof.Siblings = append(proof.Siblings, smtRoot(left, depth+1))
+			leaves = right
+		}
+	}
+
+	if len(leaves) == 1 && leaves[0].key != key {
+		neighbour := leaves[0].account
+
+		proof.HasNeighbour = true
+		proof.NeighbourKey = leaves[0].key
+		proof.NeighbourValueHash = stateValueHash(neighbour, s.Balances[neighbour], s.Account2Nonce[neighbour])
+	}
+
+	return proof
+}
+
+// Verify recomputes the state root from the proven account state and the siblings
+func (p BalanceProof) Verify(stateRoot Hash) error {
+	key := stateKey(p.Account)
+	node := Hash{}
+
+	if p.Balance != 0 || p.Nonce != 0 {
+		node = hashStateLeaf(key, stateValueHash(p.Account, p.Balance, p.Nonce))
+	} else if p.HasNeighbour {
+		if p.NeighbourKey == key {
+			return fmt.Errorf("neighbour leaf can't be the proven account")
+		}
+
+		for depth := range p.Siblings {
+			if keyBit(p.NeighbourKey, depth) != keyBit(key, depth) {
+				return fmt.Errorf("neighbour leaf isn't on the account's path")
+			}
+		}
+
+		node = hashStateLeaf(p.NeighbourKey, p.NeighbourValueHash)
+	}
+
+	for depth := len(p.Siblings) - 1; depth >= 0; depth-- {
+		if keyBit(key, depth) == 0 {
+			node = hashStateNode(node, p.Siblings[depth])
+		} else {
+			node = hashStateNode(p.Siblings[depth], node)
+		}
+	}
+
+	if node != stateRoot {
+		return fmt.Errorf("account '%s' state doesn't match the state root '%s'", p.Account, stateRoot.Hex())
+	}
+
+	return nil
+}
+
+func (s *State) stateLeaves() []stateLeaf {
+	leaves := make([]stateLeaf, 0, len(s.Balances))
+
+	accounts := make(map[Account]struct{})
+	for acc := range s.Balances {
+		accounts[acc] = struct{}{}
+	}
+	for acc := range s.Account2Nonce {
+		accounts[acc] = struct{}{}
+	}
+
+	for acc := range accounts {
+		balance, nonce := s.Balances[acc], s.Account2Nonce[acc]
+		if balance == 0 && nonce == 0 {
+			continue
+		}
+
+		key := stateKey(acc)
+		leaves = append(leaves, stateLeaf{acc, key, hashStateLeaf(key, stateValueHash(acc, balance, nonce))})
+	}
+
+	sort.Slice(leaves, func(i, j int) bool {
+		return string(leaves[i].key[:]) < string(leaves[j].key[:])
+	})
+
+	return leaves
+}
+
+// smtRoot hashes the subtree at depth holding the sorted leaves
+func smtRoot(leaves []stateLeaf, depth int) Hash {
+	switch len(leaves) {
+	case 0:
+		return Hash{}
+	case 1:
+		return leaves[0].hash
+	}
+
+	left, right := splitStateLeaves(leaves, depth)
+
+	return hashStateNode(smtRoot(left, depth+1), smtRoot(right, depth+1))
+}
+
+// splitStateLeaves splits sorted leaves on the key bit at depth
+func splitStateLeaves(leaves []stateLeaf, depth int) ([]stateLeaf, []stateLeaf) {
+	i := sort.Search(len(leaves), func(i int) bool {
+		return keyBit(leaves[i].key, depth) == 1
+	})
+
+	return leaves[:i], leaves[i:]
+}
+
+func keyBit(key Hash, depth int) int {
+	return int(key[depth/8]>>(7-uint(depth%8))) & 1
+}
+
+func stateKey(account Account) Hash {
+	return sha256.Sum256([]byte(account))
+}
+
+func stateValueHash(account Account, balance Amount, nonce uint) Hash {
+	value := make([]byte, len(account)+16)
+	copy(value, account)
+	binary.BigEndian.PutUint64(value[len(account):], uint64(balance))
+	binary.BigEndian.PutUint64(value[len(account)+8:], uint64(nonce))
+
+	return sha256.Sum256(value)
+}
+
+func hashStateLeaf(key Hash, valueHash Hash) Hash {
+	data := make([]byte, 0, 1+2*len(key))
+	data = append(data, stateLeafPrefix)
+	data = append(data, key[:]...)
+	data = append(data, valueHash[:]...)
+
+	return sha256.Sum256(data)
+}
+
+func hashStateNode(left Hash, right Hash) Hash {
+	data := make([]byte, 0, 1+2*len(left))
+	data = append(data, stateNodePrefix)
+	data = append(data, left[:]...)
+	data = append(data, right[:]...)
+
+	return sha256.Sum256(data)
+}
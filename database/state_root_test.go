@@ -0,0 +1,46 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBalanceProof(t *testing.T) {
+	state := &State{Balances: make(map[Account]Amount), Account2Nonce: make(map[Account]uint)}
+
+	for i := 0; i < 20; i++ {
+		state.Balances[NewAccount(fmt.Sprintf("account%d", i))] = Amount(i * 100)
+	}
+	state.Account2Nonce["account3"] = 2
+
+	stateRoot := state.StateRoot()
+
+	// account0 has neither balance nor nonce and is proven absent like "caesar"
+	for _, acc := range []Account{"account0", "account3", "account19", "caesar"} {
+		proof := state.BalanceProof(acc)
+
+		if err := proof.Verify(stateRoot); err != nil {
+			t.Fatalf("proof of %s should verify: %s", acc, err)
+		}
+
+		proof.Balance += 1
+		if proof.Verify(stateRoot) == nil {
+			t.Fatalf("proof of %s with a forged balance should not verify", acc)
+		}
+	}
+}
+
+func TestStateRootChangesWithBalances(t *testing.T) {
+	state := &State{Balances: map[Account]Amount{"andrej": 1000}, Account2Nonce: make(map[Account]uint)}
+	before := state.StateRoot()
+
+	state.Balances["babayaga"] = 0
+	if state.StateRoot() != before {
+		t.Fatal("an account without balance nor nonce should not change the state root")
+	}
+
+	state.Balances["babayaga"] = 1
+	if state.StateRoot() == before {
+		t.Fatal("a new balance should change the state root")
+	}
+}
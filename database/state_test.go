@@ -0,0 +1,62 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNextBlockDifficultyRetargets(t *testing.T) {
+	// a retarget every 4 blocks expects 3 * 30s between the first and the last one
+	tests := []struct {
+		name       string
+		difficulty uint32
+		blockSpan  uint64
+		expected   uint32
+	}{
+		{"harder for blocks over twice as fast", testDifficulty, 1, testDifficulty + 1},
+		{"easier for blocks over twice as slow", testDifficulty, 100, testDifficulty - 1},
+		{"unchanged for blocks on target", testDifficulty, 30, testDifficulty},
+		{"one bit easier at most", testDifficulty, 10000, testDifficulty - 1},
+		{"never easier than one bit", 1, 10000, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			genesis := fmt.Sprintf(`{"chain_id": "test-chain", "difficulty": %d, "target_block_time": 30, "retarget_interval": 4, "median_time_blocks": 1, "balances": {"andrej": 1000}}`, tc.difficulty)
+			state, _ := createTestState(t, genesis)
+			defer state.Close()
+
+			blockTime := uint64(time.Now().Unix()) - 4*tc.blockSpan - 10
+			for i := 0; i < 4; i++ {
+				if state.NextBlockDifficulty() != tc.difficulty {
+					t.Fatalf("difficulty should only change at a retarget, block '%d' has '%d'", i, state.NextBlockDifficulty())
+				}
+
+				addTestBlockAt(t, state, blockTime)
+				blockTime += tc.blockSpan
+			}
+
+			if difficulty := state.NextBlockDifficulty(); difficulty != tc.expected {
+				t.Fatalf("retargeted difficulty should be '%d', not '%d'", tc.expected, difficulty)
+			}
+		})
+	}
+}
+
+// addTestBlockAt mines the next block of the State with the block time, which
+// only needs to be later than the previous block with a single median time block
+func addTestBlockAt(t *testing.T, s *State, blockTime uint64) {
+	if minTime := s.NextBlockMinTime(); blockTime < minTime {
+		blockTime = minTime
+	}
+
+	stateRoot, err := s.NextStateRoot("andrej", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.AddBlock(sealTestBlock(t, s, blockTime, stateRoot, "andrej", nil)); err != nil {
+		t.Fatal(err)
+	}
+}
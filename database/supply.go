@@ -0,0 +1,108 @@
+package database
+
+import (
+	"fmt"
+)
+
+// Supply describes the TBB issued up to a main chain block
+type Supply struct {
+	Height    uint64 `json:"height"`
+	BlockHash Hash   `json:"block_hash"`
+	// genesis balances and block rewards minted up to the block included
+	Issued Amount `json:"issued"`
+	// TBB held by the accounts right after the block
+	Circulating Amount `json:"circulating"`
+	// block rewards still to be minted before reaching the max supply
+	Remaining Amount `json:"remaining"`
+}
+
+// GenesisSupply sums the genesis balances, whose overflow ParseGenesis and Validate reject
+func (g Genesis) GenesisSupply() Amount {
+	supply, _ := sumBalances(g.Balances)
+	return supply
+}
+
+func sumBalances(balances map[Account]Amount) (Amount, error) {
+	sum := Amount(0)
+	for account, balance := range balances {
+		var err error
+		if sum, err = sum.Add(balance); err != nil {
+			return 0, fmt.Errorf("'%s' balance: %w", account, err)
+		}
+	}
+
+	return sum, nil
+}
+
+// IssuedAt returns the TBB issued up to the block of the number included.
+// The block reward halves every HalvingInterval blocks and the last
+// rewards are cut so the issued TBB never exceed the max supply.
+func (g Genesis) IssuedAt(number uint64) Amount {
+	issued := g.GenesisSupply()
+	if issued >= g.MaxSupply {
+		return issued
+	}
+
+	remaining := uint64(g.MaxSupply - issued)
+	minted := uint64(0)
+	blocks := number + 1
+
+	for halvings := uint64(0); blocks > 0 && halvings < 64; halvings++ {
+		reward := uint64(g.BlockReward) >> halvings
+		if reward == 0 {
+			break
+		}
+
+		eraBlocks := g.HalvingInterval
+		if blocks < eraBlocks {
+			eraBlocks = blocks
+		}
+		blocks -= eraBlocks
+
+		// the comparison is made before multiplying so it can't overflow
+		if eraBlocks >= (remaining-minted)/reward+1 {
+			return g.MaxSupply
+		}
+		minted += eraBlocks * reward
+	}
+
+	return issued + Amount(minted)
+}
+
+// BlockRewardAt returns the reward of the block of the number, without the TX fees
+func (g Genesis) BlockRewardAt(number uint64) Amount {
+	if number == 0 {
+		return g.IssuedAt(0) - g.GenesisSupply()
+	}
+
+	return g.IssuedAt(number) - g.IssuedAt(number-1)
+}
+
+// SupplyAt returns the supply right after the main chain block given either
+// by hash or by number, the latest one if empty
+func (s *State) SupplyAt(block string) (Supply, error) {
+	if block == "" {
+		block = s.latestBlockHash.Hex()
+	}
+
+	hash, balances, err := s.BalancesAtBlock(block)
+	if err != nil {
+		return Supply{}, err
+	}
+
+	number, err := s.blockNumber(hash)
+	if err != nil {
+		return Supply{}, err
+	}
+
+	supply := Supply{Height: number, BlockHash: hash, Issued: s.genesis.IssuedAt(number)}
+	if supply.Circulating, err = sumBalances(balances); err != nil {
+		return Supply{}, err
+	}
+
+	if supply.Issued < s.genesis.MaxSupply {
+		supply.Remaining = s.genesis.MaxSupply - supply.Issued
+	}
+
+	return supply, nil
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestBlockRewardHalvesUntilMaxSupply(t *testing.T) {
+	gen, err := ParseGenesis([]byte(`{"block_reward": 100, "halving_interval": 2, "max_supply": 1300, "balances": {"andrej": 1000}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 100, 100, 50, 50, then 25 cut to the 0 TBB left
+	expected := []Amount{100 * TBB, 100 * TBB, 50 * TBB, 50 * TBB, 0, 0}
+	for number, reward := range expected {
+		if actual := gen.BlockRewardAt(uint64(number)); actual != reward {
+			t.Fatalf("block %d reward should be %s TBB, got %s TBB", number, reward, actual)
+		}
+	}
+
+	if gen.IssuedAt(1) != 1200*TBB || gen.IssuedAt(100) != 1300*TBB {
+		t.Fatalf("issued TBB should be 1200 after block 1 and capped to 1300, got %s and %s", gen.IssuedAt(1), gen.IssuedAt(100))
+	}
+
+	gen.MaxSupply = 1280 * TBB
+	if gen.BlockRewardAt(3) != 30*TBB || gen.IssuedAt(1<<62) != 1280*TBB {
+		t.Fatalf("last reward should be cut to the max supply, got %s TBB", gen.BlockRewardAt(3))
+	}
+}
+
+func TestSupplyAt(t *testing.T) {
+	state, _ := createTestState(t, `{"chain_id": "test-chain", "difficulty": 4, "block_reward": 100, "halving_interval": 2, "max_supply": 1300, "balances": {"andrej": 1000}}`)
+	defer state.Close()
+
+	for i := 0; i < 4; i++ {
+		mineTestBlock(t, state, "babayaga", nil)
+	}
+
+	if state.Balances["babayaga"] != 300*TBB {
+		t.Fatalf("miner should be rewarded 100, 100, 50 and 50 TBB, got %s TBB", state.Balances["babayaga"])
+	}
+
+	supply, err := state.SupplyAt("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if supply.Issued != 1250*TBB || supply.Circulating != 1250*TBB || supply.Remaining != 50*TBB {
+		t.Fatalf("supply after block 2 should be 1250 issued and circulating and 50 remaining, got %+v", supply)
+	}
+
+	if supply, err = state.SupplyAt(""); err != nil || supply.Height != 3 || supply.Remaining != 0 {
+		t.Fatalf("latest supply should have no TBB remaining, got %+v %v", supply, err)
+	}
+}
@@ -2,8 +2,13 @@ package database
 
 import (
 	"crypto/sha256"
-	"encoding/json"
+	"encoding/hex"
+	"fmt"
 	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
 )
 
 type Account string
@@ -12,33 +17,130 @@ func NewAccount(value string) Account {
 	return Account(value)
 }
 
+// PubKeyToAccount derives the account address owning the public key.
+// Same as in Ethereum: last 20 bytes of the Keccak-256 hash of the
+// uncompressed key without its 0x04 prefix.
+func PubKeyToAccount(pubKey *secp256k1.PublicKey) Account {
+	keccak := sha3.NewLegacyKeccak256()
+	keccak.Write(pubKey.SerializeUncompressed()[1:])
+	addr := keccak.Sum(nil)[12:]
+
+	return NewAccount("0x" + hex.EncodeToString(addr))
+}
+
 type Tx struct {
 	From  Account `json:"from"`
 	To    Account `json:"to"`
-	Value uint    `json:"value"`
+	Value Amount  `json:"value"`
+	Fee   Amount  `json:"fee"`
+	Nonce uint    `json:"nonce"`
 	Data  string  `json:"data"`
 	Time  uint64  `json:"time"`
+
+	// the TX can't be mined in a block lower than LockHeight, nor before the
+	// median time of the latest blocks reaches the LockTime Unix time
+	LockHeight uint64 `json:"lock_height,omitempty"`
+	LockTime   uint64 `json:"lock_time,omitempty"`
+
+	// the older encoding version of a decoded TX, 0 for the current one
+	encodingVersion byte
+}
+
+// SignedTx is a Tx plus the sender's recoverable secp256k1 signature of the Tx hash.
+// The TXs of a multisig account carry the account instead, with the signatures of its keys.
+type SignedTx struct {
+	Tx
+	Sig      []byte    `json:"signature"`
+	Multisig *Multisig `json:"multisig,omitempty"`
+	Sigs     [][]byte  `json:"signatures,omitempty"`
 }
 
-func NewTx(from Account, to Account, value uint, data string) Tx {
+func NewTx(from Account, to Account, value Amount, fee Amount, nonce uint, data string) Tx {
 	return Tx{
 		From:  from,
 		To:    to,
 		Value: value,
+		Fee:   fee,
+		Nonce: nonce,
 		Data:  data,
 		Time:  uint64(time.Now().Unix()),
 	}
 }
 
+func NewSignedTx(tx Tx, sig []byte) SignedTx {
+	return SignedTx{Tx: tx, Sig: sig}
+}
+
+// Cost is what the sender pays, the value sent plus the fee paid to the block miner
+func (t Tx) Cost() (Amount, error) {
+	return t.Value.Add(t.Fee)
+}
+
+// Validate rejects the TXs moving no TBB and the ones whose cost overflows
+func (t Tx) Validate() error {
+	if t.Value == 0 {
+		return fmt.Errorf("TX from '%s' sends no value", t.From)
+	}
+
+	if t.From == t.To {
+		return fmt.Errorf("TX from '%s' sends to its own account", t.From)
+	}
+
+	if _, err := t.Cost(); err != nil {
+		return fmt.Errorf("TX from '%s' cost: %w", t.From, err)
+	}
+
+	return nil
+}
+
 func (t Tx) IsReward() bool {
 	return t.Data == "reward"
 }
 
+// Hash is the hash of the TX binary encoding, the one the sender signs
 func (t Tx) Hash() (Hash, error) {
-	txJson, err := json.Marshal(t)
+	txBin, err := t.MarshalBinary()
 	if err != nil {
 		return Hash{}, err
 	}
 
-	return sha256.Sum256(txJson), nil
+	return sha256.Sum256(txBin), nil
+}
+
+func (t SignedTx) Hash() (Hash, error) {
+	txBin, err := t.MarshalBinary()
+	if err != nil {
+		return Hash{}, err
+	}
+
+	return sha256.Sum256(txBin), nil
+}
+
+// IsAuthentic recovers the public key from the signature and checks it
+// belongs to the TX sender. A multisig TX missing signatures is an error.
+func (t SignedTx) IsAuthentic() (bool, error) {
+	txHash, err := t.Tx.Hash()
+	if err != nil {
+		return false, err
+	}
+
+	if t.Multisig != nil {
+		if err := t.checkMultisig(txHash); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	// they would change the TX hash without invalidating the TX
+	if len(t.Sigs) > 0 {
+		return false, fmt.Errorf("TX from '%s' without multisig account must not carry multisig signatures", t.From)
+	}
+
+	pubKey, _, err := ecdsa.RecoverCompact(t.Sig, txHash[:])
+	if err != nil {
+		return false, fmt.Errorf("error while recovering public key from TX signature: %w", err)
+	}
+
+	return PubKeyToAccount(pubKey) == t.From, nil
 }
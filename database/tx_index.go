@@ -0,0 +1,193 @@
+package database
+
+import (
+	"fmt"
+)
+
+// TxLocation is the position of a mined TX in the main chain
+type TxLocation struct {
+	BlockHash   Hash   `json:"block_hash"`
+	BlockNumber uint64 `json:"block_number"`
+	Index       uint64 `json:"index"`
+}
+
+// txIndexEntry lists the hashes of a main chain block TXs in block order
+type txIndexEntry struct {
+	number uint64
+	hash   Hash
+	txs    []Hash
+}
+
+func newTxIndexEntry(b Block, hash Hash) (txIndexEntry, error) {
+	entry := txIndexEntry{number: b.Header.Number, hash: hash, txs: make([]Hash, len(b.TXs))}
+
+	for i, tx := range b.TXs {
+		txHash, err := tx.Hash()
+		if err != nil {
+			return txIndexEntry{}, err
+		}
+		entry.txs[i] = txHash
+	}
+
+	return entry, nil
+}
+
+func (e txIndexEntry) MarshalBinary() ([]byte, error) {
+	enc := newBlockLogEncoder(e.number, e.hash)
+
+	enc.length(len(e.txs))
+	for _, txHash := range e.txs {
+		enc.hash(txHash)
+	}
+
+	return enc.buf.Bytes(), nil
+}
+
+func (e *txIndexEntry) UnmarshalBinary(data []byte) error {
+	d := newDecoder(data)
+	e.number = d.uint64()
+	e.hash = d.hash()
+
+	n := d.length()
+	e.txs = make([]Hash, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		e.txs = append(e.txs, d.hash())
+	}
+
+	return d.finish()
+}
+
+// txIndex persists the TX hashes of every main chain block to txindex.db
+// and keeps the location of every TX in memory
+type txIndex struct {
+	log       *blockLog
+	locations map[Hash]TxLocation
+}
+
+func newMemoryTxIndex() *txIndex {
+	return &txIndex{newMemoryBlockLog(), make(map[Hash]TxLocation)}
+}
+
+// openTxIndex loads the locations of the indexed TXs. The index is cut at
+// its first unreadable entry, the missing entries being written again
+// as the blocks are replayed.
+func openTxIndex(path string) (*txIndex, error) {
+	log, err := openBlockLog(path)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &txIndex{log, make(map[Hash]TxLocation)}
+
+	for number := uint64(0); number < log.len(); number++ {
+		entry, err := idx.read(number)
+		if err != nil {
+			fmt.Printf("WARNING: %s, TXs are indexed again from block '%d'\n", err, number)
+			if err := log.truncate(number); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		idx.locate(entry)
+	}
+
+	return idx, nil
+}
+
+func (idx *txIndex) hashAt(number uint64) (Hash, bool) {
+	return idx.log.hashAt(number)
+}
+
+func (idx *txIndex) read(number uint64) (txIndexEntry, error) {
+	payload, err := idx.log.read(number)
+	if err != nil {
+		return txIndexEntry{}, err
+	}
+
+	var entry txIndexEntry
+	if err := entry.UnmarshalBinary(payload); err != nil {
+		return txIndexEntry{}, fmt.Errorf("error while decoding TX index entry of block '%d': %w", number, err)
+	}
+
+	return entry, nil
+}
+
+func (idx *txIndex) locate(entry txIndexEntry) {
+	for i, txHash := range entry.txs {
+		idx.locations[txHash] = TxLocation{entry.hash, entry.number, uint64(i)}
+	}
+}
+
+// append indexes the TXs of the block following the latest indexed one
+func (idx *txIndex) append(b Block, hash Hash) error {
+	entry, err := newTxIndexEntry(b, hash)
+	if err != nil {
+		return err
+	}
+
+	payload, err := entry.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if err := idx.log.append(entry.number, entry.hash, payload); err != nil {
+		return err
+	}
+
+	idx.locate(entry)
+
+	return nil
+}
+
+// truncate removes the TXs of blocks from the number on
+func (idx *txIndex) truncate(from uint64) error {
+	for number := from; number < idx.log.len(); number++ {
+		entry, err := idx.read(number)
+		if err != nil {
+			return err
+		}
+
+		for _, txHash := range entry.txs {
+			if location, ok := idx.locations[txHash]; ok && location.BlockNumber == number {
+				delete(idx.locations, txHash)
+			}
+		}
+	}
+
+	return idx.log.truncate(from)
+}
+
+func (idx *txIndex) close() error {
+	return idx.log.close()
+}
+
+// GetTxLocation returns where the TX is in the main chain, if it was mined
+func (s *State) GetTxLocation(txHash Hash) (TxLocation, bool) {
+	location, ok := s.indexes.tx.locations[txHash]
+	return location, ok
+}
+
+// GetTx returns the mined TX with its location in the main chain
+func (s *State) GetTx(txHash Hash) (SignedTx, TxLocation, error) {
+	location, ok := s.GetTxLocation(txHash)
+	if !ok {
+		return SignedTx{}, TxLocation{}, fmt.Errorf("TX '%s' is not in the main chain", txHash.Hex())
+	}
+
+	b, err := s.GetBlockByHash(location.BlockHash)
+	if err != nil {
+		return SignedTx{}, TxLocation{}, err
+	}
+
+	if location.Index >= uint64(len(b.TXs)) {
+		return SignedTx{}, TxLocation{}, fmt.Errorf("block '%s' has no TX at index %d", location.BlockHash.Hex(), location.Index)
+	}
+
+	return b.TXs[location.Index], location, nil
+}
+
+// TxConfirmations returns the number of main chain blocks from the TX block on
+func (s *State) TxConfirmations(location TxLocation) uint64 {
+	return s.latestBlock.Header.Number - location.BlockNumber + 1
+}
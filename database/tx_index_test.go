@@ -0,0 +1,72 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func TestTxIndex(t *testing.T) {
+	privKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc := PubKeyToAccount(privKey.PubKey())
+
+	state, dataDir := createTestState(t, fmt.Sprintf(`{"chain_id": "test-chain", "difficulty": %d, "balances": {"%s": 1000}}`, testDifficulty, acc))
+
+	txs := make([]SignedTx, 0)
+	for nonce := uint(1); nonce <= 2; nonce++ {
+		txs = append(txs, signTestTx(t, privKey, NewTx(acc, "babayaga", 10, 1, nonce, "")))
+	}
+
+	mineTestBlock(t, state, "andrej", nil)
+	mineTestBlock(t, state, "andrej", txs)
+	mineTestBlock(t, state, "andrej", nil)
+	blockHash := state.mainChain[1].hash
+	state.Close()
+
+	// the index is rebuilt from block.db when missing
+	if err := os.Remove(getTxIndexFilePath(dataDir)); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		state, err := NewStateFromDisk(dataDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		txHash, _ := txs[1].Hash()
+		tx, location, err := state.GetTx(txHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if location != (TxLocation{blockHash, 1, 1}) {
+			t.Fatalf("TX should be the second one of block 1, not %+v", location)
+		}
+
+		if tx.Nonce != 2 {
+			t.Fatalf("TX with nonce 2 should be returned, not %d", tx.Nonce)
+		}
+
+		if confirmations := state.TxConfirmations(location); confirmations != 2 {
+			t.Fatalf("TX should have 2 confirmations, not %d", confirmations)
+		}
+
+		state.Close()
+	}
+
+	state, err = NewStateFromDisk(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+
+	if _, ok := state.GetTxLocation(Hash{}); ok {
+		t.Fatal("unknown TX should not be located")
+	}
+}
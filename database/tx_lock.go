@@ -0,0 +1,26 @@
+package database
+
+// TxLock tells when a TX locked until a block height or time can be mined
+type TxLock struct {
+	Height uint64 `json:"height,omitempty"`
+	Time   uint64 `json:"time,omitempty"`
+	// the next block can't include the TX yet
+	Locked bool `json:"locked"`
+}
+
+// HasLock tells if the TX waits for a block height or time at all
+func (t Tx) HasLock() bool {
+	return t.LockHeight > 0 || t.LockTime > 0
+}
+
+// IsTxLocked tells if the next block can't include the TX yet. The lock time
+// is compared to the median time of the latest blocks, not to the next block
+// time, so a miner can't unlock a TX early by moving its block time forward.
+func (s *State) IsTxLocked(tx Tx) bool {
+	return tx.LockHeight > s.NextBlockNumber() || tx.LockTime > s.MedianTimePast()
+}
+
+// GetTxLock returns the TX lock, locked or not, as of the latest block
+func (s *State) GetTxLock(tx Tx) TxLock {
+	return TxLock{Height: tx.LockHeight, Time: tx.LockTime, Locked: s.IsTxLocked(tx)}
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func TestLockedTxWaitsForHeightAndMedianTime(t *testing.T) {
+	privKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc := PubKeyToAccount(privKey.PubKey())
+	genesis := fmt.Sprintf(`{"chain_id": "test-chain", "difficulty": %d, "median_time_blocks": 3, "balances": {"%s": 1000}}`, testDifficulty, acc)
+
+	state, _ := createTestState(t, genesis)
+	defer state.Close()
+
+	mineTestBlock(t, state, "andrej", nil)
+
+	tx := NewTx(acc, "babayaga", 100, 1, 1, "")
+	tx.LockHeight = state.NextBlockNumber() + 2
+	tx.LockTime = state.MedianTimePast() + 1
+	signedTx := signTestTx(t, privKey, tx)
+
+	mined := false
+	for i := 0; i < 10 && !mined; i++ {
+		if !state.IsTxLocked(tx) {
+			if state.NextBlockNumber() < tx.LockHeight || state.MedianTimePast() < tx.LockTime {
+				t.Fatalf("TX unlocked at block '%d' and median time '%d' before its lock", state.NextBlockNumber(), state.MedianTimePast())
+			}
+
+			mineTestBlock(t, state, "andrej", []SignedTx{signedTx})
+			mined = true
+			continue
+		}
+
+		if _, err := state.NextStateRoot("andrej", []SignedTx{signedTx}); err == nil || !strings.Contains(err.Error(), "is locked") {
+			t.Fatalf("locked TX should be rejected, got %v", err)
+		}
+
+		if lock := state.GetTxLock(tx); !lock.Locked || lock.Height != tx.LockHeight || lock.Time != tx.LockTime {
+			t.Fatalf("unexpected TX lock %+v", lock)
+		}
+
+		mineTestBlock(t, state, "andrej", nil)
+	}
+
+	if !mined {
+		t.Fatal("TX should unlock once its height and median time are reached")
+	}
+
+	if state.Balances["babayaga"] != 100 {
+		t.Fatalf("unlocked TX should be applied, babayaga has '%s'", state.Balances["babayaga"])
+	}
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func TestApplyTxRejectsMeaninglessAndOverflowingTXs(t *testing.T) {
+	privKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc := PubKeyToAccount(privKey.PubKey())
+
+	state := &State{Balances: map[Account]Amount{acc: 10 * TBB, "babayaga": ^Amount(0) - TBB}, Account2Nonce: make(map[Account]uint)}
+
+	rejected := map[string]Tx{
+		"sends no value":           NewTx(acc, "andrej", 0, 1, 1, ""),
+		"sends to its own account": NewTx(acc, acc, TBB, 0, 1, ""),
+		"cost":                     NewTx(acc, "andrej", ^Amount(0), 1, 1, ""),
+		"Recipient 'babayaga'":     NewTx(acc, "babayaga", 2*TBB, 0, 1, ""),
+	}
+	for message, tx := range rejected {
+		err := applyTx(signTestTx(t, privKey, tx), state)
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Fatalf("TX should be rejected with '%s', got %v", message, err)
+		}
+	}
+
+	if state.Balances[acc] != 10*TBB || state.Balances["babayaga"] != ^Amount(0)-TBB {
+		t.Fatal("rejected TXs should not change any balance")
+	}
+
+	if err := applyTx(signTestTx(t, privKey, NewTx(acc, "andrej", TBB/2, 1, 1, "")), state); err != nil {
+		t.Fatal(err)
+	}
+
+	if state.Balances[acc] != 10*TBB-TBB/2-1 || state.Balances["andrej"] != TBB/2 {
+		t.Fatalf("half a TBB should be sent, got balances %v", state.Balances)
+	}
+}
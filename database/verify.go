@@ -0,0 +1,173 @@
+package database
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ChainProblem is an inconsistency found in a block.db record
+type ChainProblem struct {
+	Record  uint64
+	Offset  int64
+	Hash    Hash
+	Message string
+}
+
+func (p ChainProblem) String() string {
+	if p.Hash.IsEmpty() {
+		return fmt.Sprintf("record %d at offset %d: %s", p.Record, p.Offset, p.Message)
+	}
+
+	return fmt.Sprintf("record %d at offset %d, block '%s': %s", p.Record, p.Offset, p.Hash.Hex(), p.Message)
+}
+
+type ChainReport struct {
+	Records  uint64
+	Problems []ChainProblem
+}
+
+func (r ChainReport) IsValid() bool {
+	return len(r.Problems) == 0
+}
+
+// VerifyChain audits the block.db of the data dir without modifying it.
+// Unlike loading the State it doesn't stop at the first invalid block:
+// every record is checked against the chain of the records before it,
+// and every problem is reported.
+func VerifyChain(dataDir string) (ChainReport, error) {
+	gen, err := LoadGenesis(getGenesisJSONFilePath(dataDir))
+	if err != nil {
+		return ChainReport{}, err
+	}
+
+	f, err := os.Open(getBlockDBFilePath(dataDir))
+	if err != nil {
+		return ChainReport{}, fmt.Errorf("error while opening block.db file: %w", err)
+	}
+	defer f.Close()
+
+	verifier, err := newState(gen, NewMemoryBlockStore(), newMemoryBalanceJournal(), newMemoryChainIndexes(gen), "")
+	if err != nil {
+		return ChainReport{}, err
+	}
+
+	report := ChainReport{}
+	reader := bufio.NewReader(f)
+	offset := int64(0)
+
+	for ; ; report.Records++ {
+		blockFS, size, err := readBlockRecord(reader)
+		if err == io.EOF {
+			break
+		}
+
+		problem := func(hash Hash, format string, args ...interface{}) {
+			report.Problems = append(report.Problems, ChainProblem{report.Records, offset, hash, fmt.Sprintf(format, args...)})
+		}
+
+		if err == errTornRecord {
+			problem(Hash{}, "torn record at the end of block.db")
+			break
+		}
+
+		if err != nil {
+			problem(Hash{}, "unreadable record: %s", err)
+
+			// without the record size the next records can't be found
+			if size == 0 {
+				problem(Hash{}, "the records after it can't be read")
+				break
+			}
+
+			offset += size
+			continue
+		}
+
+		for _, message := range verifyBlock(blockFS, verifier) {
+			problem(blockFS.Key, "%s", message)
+		}
+
+		offset += size
+	}
+
+	return report, nil
+}
+
+// verifyBlock checks the block on top of the verifier state and applies
+// whatever it can of it, so the next blocks are checked against it
+func verifyBlock(blockFS BlockFS, s *State) []string {
+	problems := make([]string, 0)
+	b := blockFS.Value
+
+	hash, err := b.Hash()
+	if err != nil {
+		return append(problems, fmt.Sprintf("block can't be hashed: %s", err))
+	}
+
+	if version := encodingVersionOf(b.Header.encodingVersion); version < amountsEncodingVersion {
+		problems = append(problems, fmt.Sprintf("encoding version %d counts whole TBB, the chain must be rebuilt", version))
+	}
+
+	if blockFS.Key != hash {
+		problems = append(problems, fmt.Sprintf("stored key doesn't match the block hash '%s'", hash.Hex()))
+	}
+
+	if expected := s.NextBlockNumber(); b.Header.Number != expected {
+		problems = append(problems, fmt.Sprintf("height must be '%d' not '%d'", expected, b.Header.Number))
+	}
+
+	if b.Header.Parent != s.latestBlockHash {
+		problems = append(problems, fmt.Sprintf("parent must be '%s' not '%s'", s.latestBlockHash.Hex(), b.Header.Parent.Hex()))
+	}
+
+	if !s.genesis.Time.IsZero() && b.Header.Time < uint64(s.genesis.Time.Unix()) {
+		problems = append(problems, fmt.Sprintf("time '%d' is before the genesis time '%d'", b.Header.Time, s.genesis.Time.Unix()))
+	}
+
+	if s.hasGenesisBlock && b.Header.Time <= s.MedianTimePast() {
+		problems = append(problems, fmt.Sprintf("time '%d' must be later than the median time '%d' of the latest blocks", b.Header.Time, s.MedianTimePast()))
+	}
+
+	if err := checkBlockLimits(b, s.genesis); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if expected := s.NextBlockDifficulty(); b.Header.Difficulty != expected {
+		problems = append(problems, fmt.Sprintf("difficulty must be '%d' not '%d'", expected, b.Header.Difficulty))
+	}
+
+	if !IsBlockHashValid(hash, b.Header.Difficulty) {
+		problems = append(problems, fmt.Sprintf("hash doesn't prove a difficulty '%d' work", b.Header.Difficulty))
+	}
+
+	if txRoot, err := TxRoot(b.TXs); err != nil || txRoot != b.Header.TxRoot {
+		problems = append(problems, "TX root doesn't match the TXs")
+	}
+
+	for i, tx := range b.TXs {
+		if err := applyTx(tx, s); err != nil {
+			problems = append(problems, fmt.Sprintf("TX %d: %s", i, err))
+		}
+	}
+
+	if err := applyBlockRewards(b, s); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if stateRoot := s.StateRoot(); b.Header.StateRoot != stateRoot {
+		problems = append(problems, fmt.Sprintf("state root must be '%s' not '%s'", stateRoot.Hex(), b.Header.StateRoot.Hex()))
+	}
+
+	if b.Header.Number%s.genesis.RetargetInterval == 0 {
+		s.retargetStartTime = b.Header.Time
+	}
+	s.pushBlockTime(b.Header.Time)
+
+	s.latestBlock = b
+	s.latestBlockHash = hash
+	s.hasGenesisBlock = true
+
+	return problems
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"encoding/hex"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestVerifyChain(t *testing.T) {
+	state, dataDir := createTestState(t, `{"chain_id": "test-chain", "difficulty": 4, "balances": {"andrej": 1000}}`)
+
+	mineTestBlock(t, state, "andrej", nil)
+	replayed := mineTestBlock(t, state, "babayaga", nil)
+	mineTestBlock(t, state, "andrej", nil)
+	state.Close()
+
+	report, err := VerifyChain(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.IsValid() || report.Records != 3 {
+		t.Fatalf("3 valid blocks should be verified, got %d records with problems %v", report.Records, report.Problems)
+	}
+
+	record, err := encodeBlockRecord(replayed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendToFile(t, getBlockDBFilePath(dataDir), record)
+	appendToFile(t, getBlockDBFilePath(dataDir), []byte{recordMagic, 0, 0})
+
+	report, err = VerifyChain(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	problems := make(map[uint64]int)
+	for _, problem := range report.Problems {
+		problems[problem.Record]++
+	}
+
+	// the replayed block has the wrong height, parent, time and state root
+	if problems[3] != 4 || problems[4] != 1 || len(problems) != 2 {
+		t.Fatalf("replayed block and torn record problems should be reported, got %v", report.Problems)
+	}
+}
+
+func TestVerifyChainRejectsWhatApplyBlockRejects(t *testing.T) {
+	state, dataDir := createTestState(t, `{"chain_id": "test-chain", "difficulty": 4, "balances": {"andrej": 1000}}`)
+	mineTestBlock(t, state, "andrej", nil)
+
+	// the fees of its TX and the block reward overflow the miner balance
+	tx := NewSignedTx(NewTx("andrej", "babayaga", 0, math.MaxUint64, 1, ""), nil)
+	b, err := NewBlock(state.LatestBlockHash(), state.NextBlockNumber(), 0, state.NextBlockMinTime(), state.NextBlockDifficulty(), Hash{}, "andrej", []SignedTx{tx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	overflowing := findTestPoW(t, b)
+	state.Close()
+
+	blockBin, err := hex.DecodeString(olderVersionBlocks[0].block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wholeTBB Block
+	if err := wholeTBB.UnmarshalBinary(blockBin); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, b := range []Block{overflowing, wholeTBB} {
+		record, err := encodeBlockRecord(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		appendToFile(t, getBlockDBFilePath(dataDir), record)
+	}
+
+	report, err := VerifyChain(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	problems := make(map[uint64]string)
+	for _, problem := range report.Problems {
+		problems[problem.Record] += problem.Message + "\n"
+	}
+
+	if !strings.Contains(problems[1], "miner 'andrej' balance") {
+		t.Fatalf("the overflowing block reward should be reported, got %v", report.Problems)
+	}
+
+	if !strings.Contains(problems[2], "counts whole TBB") {
+		t.Fatalf("the version 1 block should be reported, got %v", report.Problems)
+	}
+}
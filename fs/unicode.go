@@ -7,5 +7,5 @@ import (
 
 func Unicode(s string) string {
 	r, _ := strconv.ParseInt(strings.TrimPrefix(s, "\\U"), 16, 32)
-	return string(r)
+	return string(rune(r))
 }
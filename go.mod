@@ -2,9 +2,15 @@ module github.com/disharjayanth/golangBlockchain
 
 go 1.17
 
-require github.com/spf13/cobra v1.3.0
+require (
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.0.1
+	github.com/spf13/cobra v1.3.0
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
+)
 
 require (
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
+	golang.org/x/sys v0.0.0-20211205182925-97ca703d548d // indirect
 )
@@ -8,9 +8,13 @@ import (
 )
 
 func writeErrRes(w http.ResponseWriter, err error) {
+	writeErrResWithStatus(w, http.StatusInternalServerError, err)
+}
+
+func writeErrResWithStatus(w http.ResponseWriter, status int, err error) {
 	jsonErrRes, _ := json.Marshal(ErrRes{err.Error()})
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusInternalServerError)
+	w.WriteHeader(status)
 	w.Write(jsonErrRes)
 }
 
@@ -39,3 +43,29 @@ func readReq(r *http.Request, reqBody interface{}) error {
 
 	return nil
 }
+
+// writeBinRes writes binary encoded content, errors are still JSON ErrRes
+func writeBinRes(w http.ResponseWriter, content []byte) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
+
+func readBinRes(r *http.Response) ([]byte, error) {
+	if r.StatusCode != http.StatusOK {
+		errRes := ErrRes{}
+		if err := readRes(r, &errRes); err != nil {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf(errRes.Error)
+	}
+
+	content, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading response body in readBinRes func: %w", err)
+	}
+	defer r.Body.Close()
+
+	return content, nil
+}
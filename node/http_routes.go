@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/disharjayanth/golangBlockchain/database"
+	"github.com/disharjayanth/golangBlockchain/wallet"
 )
 
 type ErrRes struct {
@@ -20,15 +23,30 @@ type AddPeerRes struct {
 }
 
 type BalancesRes struct {
-	Hash     database.Hash             `json:"block_hash"`
-	Balances map[database.Account]uint `json:"balances"`
+	Hash     database.Hash                        `json:"block_hash"`
+	Balances map[database.Account]database.Amount `json:"balances"`
+}
+
+type BalanceProofRes struct {
+	Hash      database.Hash         `json:"block_hash"`
+	StateRoot database.Hash         `json:"state_root"`
+	Proof     database.BalanceProof `json:"proof"`
 }
 
 type TxAddReq struct {
-	From  string `json:"from"`
-	To    string `json:"to"`
-	Value uint   `json:"value"`
-	Data  string `json:"data"`
+	From    string          `json:"from"`
+	FromPwd string          `json:"from_pwd"`
+	To      string          `json:"to"`
+	Value   database.Amount `json:"value"`
+	Fee     database.Amount `json:"fee"`
+	Data    string          `json:"data"`
+
+	// optional, the TX waits in the mempool until the block height and median block time
+	LockHeight uint64 `json:"lock_height,omitempty"`
+	LockTime   uint64 `json:"lock_time,omitempty"`
+
+	// a TX signed offline, like a multisig TX, replaces all the other fields
+	SignedTx *database.SignedTx `json:"signed_tx,omitempty"`
 }
 
 type TxAddRes struct {
@@ -39,22 +57,74 @@ type TxAddRes struct {
 	Success bool `json:"success"`
 }
 
+type TxProofRes struct {
+	BlockHash database.Hash        `json:"block_hash"`
+	Header    database.BlockHeader `json:"header"`
+	Proof     database.MerkleProof `json:"proof"`
+}
+
+// TxRes reports whether the TX waits in the mempool or was mined,
+// with its location and confirmations once mined, and when a locked TX unlocks
+type TxRes struct {
+	Hash          database.Hash        `json:"hash"`
+	Status        string               `json:"status"`
+	Tx            database.SignedTx    `json:"tx"`
+	Location      *database.TxLocation `json:"location,omitempty"`
+	Confirmations uint64               `json:"confirmations"`
+	Lock          *database.TxLock     `json:"lock,omitempty"`
+}
+
+type AccountTXsRes struct {
+	Account database.Account     `json:"account"`
+	Total   uint64               `json:"total"`
+	Offset  uint64               `json:"offset"`
+	TXs     []database.AccountTx `json:"txs"`
+}
+
 type StatusRes struct {
+	ChainID    string              `json:"chain_id"`
 	Hash       database.Hash       `json:"block_hash"`
 	Number     uint64              `json:"block_number"`
+	TotalWork  *big.Int            `json:"total_work"`
 	KnownPeers map[string]PeerNode `json:"peer_known"`
+}
+
+// listBalancesHandler returns the latest balances, or the ones
+// after the block given by number or hash
+func listBalancesHandler(w http.ResponseWriter, r *http.Request, state *database.State) {
+	block := r.URL.Query().Get(endPointBalancesListQueryKeyBlock)
+	if block == "" {
+		writeRes(w, BalancesRes{state.LatestBlockHash(), state.Balances})
+		return
+	}
 
-	// exchange pending TXs as part of the periodic
-	// Sync() interval
-	PendingTXs []database.Tx `json:"pending_txs"`
+	hash, balances, err := state.BalancesAtBlock(block)
+	if err != nil {
+		writeErrRes(w, err)
+		return
+	}
+
+	writeRes(w, BalancesRes{hash, balances})
 }
 
-type SyncRes struct {
-	Blocks []database.Block `json:"blocks"`
+// balanceProofHandler returns the account balance with its proof
+// against the state root of the latest block header
+func balanceProofHandler(w http.ResponseWriter, r *http.Request, state *database.State) {
+	account := database.NewAccount(r.URL.Query().Get(endPointBalanceProofQueryKeyAccount))
+
+	writeRes(w, BalanceProofRes{state.LatestBlockHash(), state.StateRoot(), state.BalanceProof(account)})
 }
 
-func listBalancesHandler(w http.ResponseWriter, r *http.Request, state *database.State) {
-	writeRes(w, BalancesRes{state.LatestBlockHash(), state.Balances})
+// chainSupplyHandler returns the issued, circulating and remaining
+// supply after the latest block or the one given by number or hash
+func chainSupplyHandler(w http.ResponseWriter, r *http.Request, state *database.State) {
+	supply, err := state.SupplyAt(r.URL.Query().Get(endPointChainSupplyQueryKeyBlock))
+	if err != nil {
+		writeErrRes(w, err)
+		return
+	}
+
+	writeRes(w, supply)
 }
 
 func txAddHandler(w http.ResponseWriter, r *http.Request, node *Node) {
@@ -65,9 +135,35 @@ func txAddHandler(w http.ResponseWriter, r *http.Request, node *Node) {
 		return
 	}
 
-	tx := database.NewTx(database.NewAccount(req.From), database.NewAccount(req.To), req.Value, req.Data)
+	if req.SignedTx != nil {
+		if err := node.AddPendingTX(*req.SignedTx, node.info); err != nil {
+			writeErrRes(w, err)
+			return
+		}
 
-	err = node.AddPendingTX(tx, node.info)
+		writeRes(w, TxAddRes{Success: true})
+		return
+	}
+
+	from := database.NewAccount(req.From)
+
+	if req.FromPwd == "" {
+		writeErrRes(w, fmt.Errorf("password to decrypt the %s account is required. 'from_pwd' is empty", from))
+		return
+	}
+
+	nonce := node.getNextAccountNonce(from)
+	tx := database.NewTx(from, database.NewAccount(req.To), req.Value, req.Fee, nonce, req.Data)
+	tx.LockHeight = req.LockHeight
+	tx.LockTime = req.LockTime
+
+	signedTx, err := wallet.SignTxWithKeystoreAccount(tx, from, req.FromPwd, wallet.GetKeystoreDirPath(node.dataDir))
+	if err != nil {
+		writeErrRes(w, err)
+		return
+	}
+
+	err = node.AddPendingTX(signedTx, node.info)
 
 	if err != nil {
 		writeErrRes(w, err)
@@ -77,12 +173,129 @@ func txAddHandler(w http.ResponseWriter, r *http.Request, node *Node) {
 	writeRes(w, TxAddRes{Success: true})
 }
 
+// txProofHandler returns the block header and the Merkle path proving
+// the TX is included in it, verifiable against the header TxRoot alone
+func txProofHandler(w http.ResponseWriter, r *http.Request, node *Node) {
+	blockHash := database.Hash{}
+	err := blockHash.UnmarshalText([]byte(r.URL.Query().Get(endPointTxProofQueryKeyBlock)))
+	if err != nil {
+		writeErrRes(w, err)
+		return
+	}
+
+	txHash := database.Hash{}
+	err = txHash.UnmarshalText([]byte(r.URL.Query().Get(endPointTxProofQueryKeyTx)))
+	if err != nil {
+		writeErrRes(w, err)
+		return
+	}
+
+	block, err := node.state.GetBlockByHash(blockHash)
+	if err != nil {
+		writeErrRes(w, err)
+		return
+	}
+
+	proof, err := database.TxInclusionProof(block, txHash)
+	if err != nil {
+		writeErrRes(w, err)
+		return
+	}
+
+	writeRes(w, TxProofRes{blockHash, block.Header, proof})
+}
+
+// txHandler looks the TX given by the path hash up in the mempool, then in the main chain
+func txHandler(w http.ResponseWriter, r *http.Request, node *Node) {
+	txHash := database.Hash{}
+	err := txHash.UnmarshalText([]byte(strings.TrimPrefix(r.URL.Path, endPointTx)))
+	if err != nil {
+		writeErrResWithStatus(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if tx, isPending := node.pendingTXs[txHash.Hex()]; isPending {
+		res := TxRes{Hash: txHash, Status: TxStatusPending, Tx: tx, Lock: txLock(node.state, tx)}
+		if res.Lock != nil && res.Lock.Locked {
+			res.Status = TxStatusLocked
+		}
+
+		writeRes(w, res)
+		return
+	}
+
+	if _, isMined := node.state.GetTxLocation(txHash); !isMined {
+		writeErrResWithStatus(w, http.StatusNotFound, fmt.Errorf("TX '%s' not found", txHash.Hex()))
+		return
+	}
+
+	tx, location, err := node.state.GetTx(txHash)
+	if err != nil {
+		writeErrRes(w, err)
+		return
+	}
+
+	writeRes(w, TxRes{
+		Hash:          txHash,
+		Status:        TxStatusMined,
+		Tx:            tx,
+		Location:      &location,
+		Confirmations: node.state.TxConfirmations(location),
+		Lock:          txLock(node.state, tx),
+	})
+}
+
+// txLock returns nil for a TX without lock
+func txLock(state *database.State, tx database.SignedTx) *database.TxLock {
+	if !tx.HasLock() {
+		return nil
+	}
+
+	lock := state.GetTxLock(tx.Tx)
+
+	return &lock
+}
+
+// accountTXsHandler returns a page of the TXs sent or received by the account
+// and the block rewards it was credited, newest first
+func accountTXsHandler(w http.ResponseWriter, r *http.Request, state *database.State) {
+	path := strings.TrimPrefix(r.URL.Path, endPointAccount)
+	if !strings.HasSuffix(path, endPointAccountTXsSuffix) {
+		writeErrResWithStatus(w, http.StatusNotFound, fmt.Errorf("unknown endpoint '%s'", r.URL.Path))
+		return
+	}
+	account := database.NewAccount(strings.TrimSuffix(path, endPointAccountTXsSuffix))
+
+	offset := uint64(0)
+	limit := uint64(DefaultAccountTXsLimit)
+	var err error
+
+	if raw := r.URL.Query().Get(endPointAccountTXsQueryKeyOffset); raw != "" {
+		if offset, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			writeErrResWithStatus(w, http.StatusBadRequest, fmt.Errorf("invalid offset: %w", err))
+			return
+		}
+	}
+
+	if raw := r.URL.Query().Get(endPointAccountTXsQueryKeyLimit); raw != "" {
+		if limit, err = strconv.ParseUint(raw, 10, 64); err != nil || limit == 0 || limit > maxAccountTXsLimit {
+			writeErrResWithStatus(w, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d", maxAccountTXsLimit))
+			return
+		}
+	}
+
+	txs, total := state.GetAccountTXs(account, offset, limit)
+
+	writeRes(w, AccountTXsRes{account, total, offset, txs})
+}
+
 func statusHandler(w http.ResponseWriter, r *http.Request, node *Node) {
 	res := StatusRes{
+		ChainID:    node.state.Genesis().ChainID,
 		Hash:       node.state.LatestBlockHash(),
 		Number:     node.state.LatestBlock().Header.Number,
+		TotalWork:  node.state.TotalWork(),
 		KnownPeers: node.knownPeers,
-		PendingTXs: node.getPendingTXsAsArray(),
 	}
 
 	writeRes(w, res)
@@ -113,15 +326,31 @@ func syncHandler(w http.ResponseWriter, r *http.Request, node *Node) {
 		return
 	}
 
-	blocks, err := database.GetBlocksAfter(hash, node.dataDir)
+	blocks, err := node.state.GetBlocksAfter(hash)
 	if err != nil {
 		writeErrRes(w, err)
 		return
 	}
 
-	writeRes(w, SyncRes{
-		Blocks: blocks,
-	})
+	blocksBin, err := database.EncodeBlocks(blocks)
+	if err != nil {
+		writeErrRes(w, err)
+		return
+	}
+
+	writeBinRes(w, blocksBin)
+}
+
+// pendingTXsHandler returns the mempool binary encoded, the pending TXs
+// are exchanged between nodes as part of the periodic Sync() interval
+func pendingTXsHandler(w http.ResponseWriter, r *http.Request, node *Node) {
+	txsBin, err := database.EncodeTXs(node.getPendingTXsAsArray())
+	if err != nil {
+		writeErrRes(w, err)
+		return
+	}
+
+	writeBinRes(w, txsBin)
 }
 
 func addPeerHandler(w http.ResponseWriter, r *http.Request, node *Node) {
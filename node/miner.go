@@ -11,66 +11,89 @@ import (
 )
 
 type PendingBlock struct {
-	parent database.Hash
-	number uint64
-	time   uint64
-	miner  database.Account
-	txs    []database.Tx
+	parent     database.Hash
+	number     uint64
+	time       uint64
+	difficulty uint32
+	stateRoot  database.Hash
+	miner      database.Account
+	txs        []database.SignedTx
 }
 
-func NewPendingBlock(parent database.Hash, number uint64, miner database.Account, txs []database.Tx) PendingBlock {
-	return PendingBlock{parent, number, uint64(time.Now().Unix()), miner, txs}
+func NewPendingBlock(parent database.Hash, number uint64, difficulty uint32, stateRoot database.Hash, miner database.Account, txs []database.SignedTx) PendingBlock {
+	return PendingBlock{parent, number, uint64(time.Now().Unix()), difficulty, stateRoot, miner, txs}
 }
 
+// Mine seals the pending block
 func Mine(ctx context.Context, pb PendingBlock) (database.Block, error) {
-	if len(pb.txs) == 0 {
-		return database.Block{}, fmt.Errorf("mining empty blocks is not allowed")
+	start := time.Now()
+
+	// the TXs and so the header TX root don't change between attempts, only the nonce
+	block, err := database.NewBlock(pb.parent, pb.number, 0, pb.time, pb.difficulty, pb.stateRoot, pb.miner, pb.txs)
+	if err != nil {
+		return database.Block{}, fmt.Errorf("couldn't mine block. %s", err.Error())
 	}
 
-	start := time.Now()
+	block, hash, attempt, err := seal(ctx, block)
+	if err != nil {
+		return database.Block{}, err
+	}
+
+	fmt.Printf("\nMined new block '%x' using Pow %s\n", hash, fs.Unicode("\\U1F389"))
+	fmt.Printf("\tHeight: '%v'\n", block.Header.Number)
+	fmt.Printf("\tNonce: '%v'\n", block.Header.Nonce)
+	fmt.Printf("\tCreated: '%v'\n", block.Header.Time)
+	fmt.Printf("\tDifficulty: '%v'\n", block.Header.Difficulty)
+	fmt.Printf("\tMiner: '%v'\n", block.Header.Miner)
+	fmt.Printf("\tParent: '%v'\n", block.Header.Parent.Hex())
+
+	fmt.Printf("\tAttempt: '%v'\n", attempt)
+	fmt.Printf("\tTime: %s\n", time.Since(start))
+
+	return block, nil
+}
+
+// Seal searches a nonce giving the block a hash valid for its difficulty
+func Seal(ctx context.Context, block database.Block) (database.Block, error) {
+	block, _, _, err := seal(ctx, block)
+	return block, err
+}
+
+func seal(ctx context.Context, block database.Block) (database.Block, database.Hash, int, error) {
 	attempt := 0
-	var block database.Block
 	var hash database.Hash
-	var nonce uint32
 
-	for !database.IsBlockHashValid(hash) {
+	// an all zeros hash is valid for any difficulty, so at least one attempt must run
+	for attempt == 0 || !database.IsBlockHashValid(hash, block.Header.Difficulty) {
 		select {
 		case <-ctx.Done():
 			fmt.Println("Mining cancelled!")
-			return database.Block{}, fmt.Errorf("mining cancelled %s", ctx.Err())
+			return database.Block{}, database.Hash{}, attempt, fmt.Errorf("mining cancelled %s", ctx.Err())
 		default:
 		}
 
 		attempt++
-		nonce = generateNonce()
+		block.Header.Nonce = generateNonce()
 
 		if attempt%1000000 == 0 || attempt == 1 {
-			fmt.Printf("Mining %d Pending Txs. Attempt:%d\n", len(pb.txs), attempt)
+			fmt.Printf("Mining %d Pending Txs. Attempt:%d\n", len(block.TXs), attempt)
 		}
 
-		block = database.NewBlock(pb.parent, pb.number, nonce, pb.time, pb.miner, pb.txs)
 		blockHash, err := block.Hash()
 		if err != nil {
-			return database.Block{}, fmt.Errorf("couldn't mine block. %s", err.Error())
+			return database.Block{}, database.Hash{}, attempt, fmt.Errorf("couldn't mine block. %s", err.Error())
 		}
 
 		hash = blockHash
 	}
 
-	fmt.Printf("\nMined new block '%x' using Pow %s\n", hash, fs.Unicode("\\U1F389"))
-	fmt.Printf("\tHeight: '%v'\n", block.Header.Number)
-	fmt.Printf("\tNonce: '%v'\n", block.Header.Nonce)
-	fmt.Printf("\tCreated: '%v'\n", block.Header.Time)
-	fmt.Printf("\tMiner: '%v'\n", block.Header.Miner)
-	fmt.Printf("\tParent: '%v'\n", block.Header.Parent.Hex())
-
-	fmt.Printf("\tAttempt: '%v'\n", attempt)
-	fmt.Printf("\tTime: %s\n", time.Since(start))
+	return block, hash, attempt, nil
+}
 
-	return block, nil
+func init() {
+	rand.Seed(time.Now().UTC().UnixNano())
 }
 
 func generateNonce() uint32 {
-	rand.Seed(time.Now().UTC().UnixNano())
 	return rand.Uint32()
 }
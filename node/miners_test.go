@@ -7,15 +7,22 @@ import (
 	"time"
 
 	"github.com/disharjayanth/golangBlockchain/database"
+	"github.com/disharjayanth/golangBlockchain/wallet"
 )
 
+// testDifficulty keeps the mining in tests to a fraction of a second
+const testDifficulty = 12
+
+// testSlowDifficulty makes mining take a few seconds, for tests interrupting it
+const testSlowDifficulty = 20
+
 func TestValidBlockHash(t *testing.T) {
 	hexHash := "000000fa04f816039...a4db586086168edfa"
 	var hash = database.Hash{}
 
 	hex.Decode(hash[:], []byte(hexHash))
 
-	isValid := database.IsBlockHashValid(hash)
+	isValid := database.IsBlockHashValid(hash, 24)
 
 	if !isValid {
 		t.Fatalf("hash '%x' should be a valid hash", hexHash)
@@ -26,7 +33,7 @@ func TestInvalidBlockHash(t *testing.T) {
 	hexHash := "000001fa04f8160395c387277f8b2f14837603383d33809a4db586086168edfa"
 	var hash = database.Hash{}
 	hex.Decode(hash[:], []byte(hexHash))
-	isValid := database.IsBlockHashValid(hash)
+	isValid := database.IsBlockHashValid(hash, 24)
 	if isValid {
 		t.Fatal("hash is not suppose to be valid")
 	}
@@ -34,7 +41,10 @@ func TestInvalidBlockHash(t *testing.T) {
 
 func TestMine(t *testing.T) {
 	miner := database.NewAccount("andrej")
-	pendingBlock := createRandomPendingBlock(miner)
+	pendingBlock, err := createRandomPendingBlock(miner, testDifficulty)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	ctx := context.Background()
 
@@ -46,7 +56,7 @@ func TestMine(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !database.IsBlockHashValid(minedBlockHash) {
+	if !database.IsBlockHashValid(minedBlockHash, testDifficulty) {
 		t.Fatal()
 	}
 
@@ -57,23 +67,40 @@ func TestMine(t *testing.T) {
 
 func TestMineWithTimeout(t *testing.T) {
 	miner := database.NewAccount("andrej")
-	pendingBlock := createRandomPendingBlock(miner)
+	pendingBlock, err := createRandomPendingBlock(miner, database.DefaultDifficulty)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	ctx, _ := context.WithTimeout(context.Background(), time.Microsecond*100)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Microsecond*100)
+	defer cancel()
 
-	_, err := Mine(ctx, pendingBlock)
+	_, err = Mine(ctx, pendingBlock)
 	if err == nil {
 		t.Fatal(err)
 	}
 }
 
-func createRandomPendingBlock(miner database.Account) PendingBlock {
+func createRandomPendingBlock(miner database.Account, difficulty uint32) (PendingBlock, error) {
+	privKey, err := wallet.NewRandomKey()
+	if err != nil {
+		return PendingBlock{}, err
+	}
+
+	from := database.PubKeyToAccount(privKey.PubKey())
+
+	tx := database.Tx{From: from, To: "babayaga", Value: 1, Nonce: 1, Time: 1579451695, Data: ""}
+	signedTx, err := wallet.SignTx(tx, privKey)
+	if err != nil {
+		return PendingBlock{}, err
+	}
+
 	return NewPendingBlock(
 		database.Hash{},
 		1,
+		difficulty,
+		database.Hash{},
 		miner,
-		[]database.Tx{
-			database.Tx{From: "andrej", To: "babayaga", Value: 1, Time: 1579451695, Data: ""},
-		},
-	)
+		[]database.SignedTx{signedTx},
+	), nil
 }
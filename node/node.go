@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/disharjayanth/golangBlockchain/database"
@@ -18,13 +19,46 @@ const endPointStatus = "/node/status"
 const endPointSync = "/node/sync"
 const endPointSyncQueryKeyFromBlock = "fromBlock"
 
+const endPointPendingTXs = "/node/pending"
+
+const endPointBalancesList = "/balances/list"
+const endPointBalancesListQueryKeyBlock = "block"
+
+const endPointBalanceProof = "/balances/proof"
+const endPointBalanceProofQueryKeyAccount = "account"
+
+// the TX hash follows the endpoint path, as in /tx/<hash>
+const endPointTx = "/tx/"
+const TxStatusPending = "pending"
+const TxStatusMined = "mined"
+
+// a pending TX the next block can't include before its lock height or time
+const TxStatusLocked = "locked"
+
+// maxPendingTxLockBlocks bounds how many blocks, or their target time,
+// a pending TX may stay locked for, the node mines empty blocks meanwhile
+const maxPendingTxLockBlocks = 100
+
+const endPointTxProof = "/tx/proof"
+const endPointTxProofQueryKeyBlock = "block"
+const endPointTxProofQueryKeyTx = "tx"
+
+// the account name is part of the path, as in /account/<name>/txs
+const endPointAccount = "/account/"
+const endPointAccountTXsSuffix = "/txs"
+const endPointAccountTXsQueryKeyOffset = "offset"
+const endPointAccountTXsQueryKeyLimit = "limit"
+const DefaultAccountTXsLimit = 20
+const maxAccountTXsLimit = 100
+
+const endPointChainSupply = "/chain/supply"
+const endPointChainSupplyQueryKeyBlock = "block"
+
 const endPointAddPeer = "/node/peer"
 const endPointAddPeerQueryKeyIP = "ip"
 const endPointAddPeerQueryKeyPort = "port"
 const endPointAddPeerQueryKeyMiner = "miner"
 
-const miningIntervalSeconds = 10
-
 type PeerNode struct {
 	IP          string           `json:"ip"`
 	Port        uint64           `json:"port"`
@@ -45,10 +79,10 @@ type Node struct {
 
 	state           *database.State
 	knownPeers      map[string]PeerNode
-	pendingTXs      map[string]database.Tx
-	archivedTXs     map[string]database.Tx
+	pendingTXs      map[string]database.SignedTx
+	archivedTXs     map[string]database.SignedTx
 	newSyncedBlocks chan database.Block
-	newPendingTXs   chan database.Tx
+	newPendingTXs   chan database.SignedTx
 	isMining        bool
 }
 
@@ -60,10 +94,10 @@ func New(dataDir string, ip string, port uint64, account database.Account, boots
 		dataDir:         dataDir,
 		info:            NewPeerNode(ip, port, false, account, true),
 		knownPeers:      knownPeers,
-		pendingTXs:      make(map[string]database.Tx),
-		archivedTXs:     make(map[string]database.Tx),
+		pendingTXs:      make(map[string]database.SignedTx),
+		archivedTXs:     make(map[string]database.SignedTx),
 		newSyncedBlocks: make(chan database.Block),
-		newPendingTXs:   make(chan database.Tx, 1000),
+		newPendingTXs:   make(chan database.SignedTx, 1000),
 		isMining:        false,
 	}
 }
@@ -96,27 +130,53 @@ func (n *Node) Run(ctx context.Context) error {
 	go n.sync(ctx)
 	go n.mine(ctx)
 
-	http.HandleFunc("/balances/list", func(w http.ResponseWriter, r *http.Request) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(endPointBalancesList, func(w http.ResponseWriter, r *http.Request) {
 		listBalancesHandler(w, r, state)
 	})
 
-	http.HandleFunc("/tx/add", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/tx/add", func(w http.ResponseWriter, r *http.Request) {
 		txAddHandler(w, r, n)
 	})
 
-	http.HandleFunc(endPointStatus, func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(endPointBalanceProof, func(w http.ResponseWriter, r *http.Request) {
+		balanceProofHandler(w, r, state)
+	})
+
+	mux.HandleFunc(endPointTx, func(w http.ResponseWriter, r *http.Request) {
+		txHandler(w, r, n)
+	})
+
+	mux.HandleFunc(endPointTxProof, func(w http.ResponseWriter, r *http.Request) {
+		txProofHandler(w, r, n)
+	})
+
+	mux.HandleFunc(endPointAccount, func(w http.ResponseWriter, r *http.Request) {
+		accountTXsHandler(w, r, state)
+	})
+
+	mux.HandleFunc(endPointChainSupply, func(w http.ResponseWriter, r *http.Request) {
+		chainSupplyHandler(w, r, state)
+	})
+
+	mux.HandleFunc(endPointStatus, func(w http.ResponseWriter, r *http.Request) {
 		statusHandler(w, r, n)
 	})
 
-	http.HandleFunc(endPointSync, func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(endPointSync, func(w http.ResponseWriter, r *http.Request) {
 		syncHandler(w, r, n)
 	})
 
-	http.HandleFunc(endPointAddPeer, func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(endPointPendingTXs, func(w http.ResponseWriter, r *http.Request) {
+		pendingTXsHandler(w, r, n)
+	})
+
+	mux.HandleFunc(endPointAddPeer, func(w http.ResponseWriter, r *http.Request) {
 		addPeerHandler(w, r, n)
 	})
 
-	server := &http.Server{Addr: fmt.Sprintf(":%d", n.info.Port)}
+	server := &http.Server{Addr: fmt.Sprintf(":%d", n.info.Port), Handler: mux}
 
 	go func() {
 		<-ctx.Done()
@@ -140,7 +200,7 @@ func (n *Node) mine(ctx context.Context) error {
 	var miningCtx context.Context
 	var stopCurrentMining context.CancelFunc
 
-	ticker := time.NewTicker(time.Second * miningIntervalSeconds)
+	ticker := time.NewTicker(time.Second * time.Duration(n.state.Genesis().MiningInterval))
 
 	for {
 		select {
@@ -173,25 +233,50 @@ func (n *Node) mine(ctx context.Context) error {
 }
 
 func (n *Node) minePendingTXs(ctx context.Context) error {
+	txs := n.getPendingBlockTXs()
+
+	// the height and median time only move forward with new blocks, so the node
+	// only mines an empty block to move the chain towards unlocking its pending locked TXs
+	if len(txs) == 0 && !n.hasLockedPendingTXs() {
+		return nil
+	}
+
+	stateRoot, err := n.state.NextStateRoot(n.info.Account, txs)
+	if err != nil {
+		return err
+	}
+
 	blockToMine := NewPendingBlock(
 		n.state.LatestBlockHash(),
-		n.state.LatestBlock().Header.Number+1,
+		n.state.NextBlockNumber(),
+		n.state.NextBlockDifficulty(),
+		stateRoot,
 		n.info.Account,
-		n.getPendingTXsAsArray(),
+		txs,
 	)
 
+	// a clock behind the latest blocks would mine an invalid block
+	if minTime := n.state.NextBlockMinTime(); blockToMine.time < minTime {
+		blockToMine.time = minTime
+	}
+
 	minedBlock, err := Mine(ctx, blockToMine)
 	if err != nil {
 		return err
 	}
 
-	n.removeMinedPendingTXs(minedBlock)
-
-	_, err = n.state.AddBlock(minedBlock)
+	blockHash, err := n.state.AddBlock(minedBlock)
 	if err != nil {
 		return err
 	}
 
+	// a block mined on top of an outdated tip only joins a side branch
+	if n.state.IsMainChainBlock(blockHash) {
+		n.removeMinedPendingTXs(minedBlock)
+	}
+	n.restoreOrphanedTXs()
+	n.evictStalePendingTXs()
+
 	return nil
 }
 
@@ -211,6 +296,17 @@ func (n *Node) removeMinedPendingTXs(block database.Block) {
 	}
 }
 
+// evictStalePendingTXs drops the pending TXs whose nonce the chain already used,
+// like a TX of a block the chain reorganised away whose nonce another TX took
+func (n *Node) evictStalePendingTXs() {
+	for txHash, tx := range n.pendingTXs {
+		if tx.Nonce < n.state.GetNextAccountNonce(tx.From) {
+			fmt.Printf("Stale TX '%s' evicted from the mempool, nonce '%d' was already used\n", txHash, tx.Nonce)
+			delete(n.pendingTXs, txHash)
+		}
+	}
+}
+
 func (n *Node) AddPeer(peer PeerNode) {
 	n.knownPeers[peer.TcpAddress()] = peer
 }
@@ -229,12 +325,48 @@ func (n *Node) IsKnownPeer(peer PeerNode) bool {
 	return isKnownPeer
 }
 
-func (n *Node) AddPendingTX(tx database.Tx, fromPeer PeerNode) error {
+func (n *Node) AddPendingTX(tx database.SignedTx, fromPeer PeerNode) error {
 	txHash, err := tx.Hash()
 	if err != nil {
 		return err
 	}
 
+	isAuthentic, err := tx.IsAuthentic()
+	if err != nil {
+		return err
+	}
+
+	if !isAuthentic {
+		return fmt.Errorf("TX from '%s' is not signed by the sender", tx.From)
+	}
+
+	if err := tx.Validate(); err != nil {
+		return err
+	}
+
+	// a TX of an older block is hashed with its version, which a new block can't encode
+	if tx.EncodingVersion() != database.EncodingVersion {
+		return fmt.Errorf("TX from '%s' is encoded with version %d, not %d", tx.From, tx.EncodingVersion(), database.EncodingVersion)
+	}
+
+	// TXs queued before Run() loads the state are checked when mined
+	if n.state != nil && tx.Nonce < n.state.GetNextAccountNonce(tx.From) {
+		return fmt.Errorf("TX from '%s' nonce '%d' was already used", tx.From, tx.Nonce)
+	}
+
+	if n.state != nil {
+		if err := n.checkPendingTxLock(tx.Tx); err != nil {
+			return err
+		}
+	}
+
+	// the first TX of a nonce wins, a different one could never be mined after it
+	for pendingHash, pendingTx := range n.pendingTXs {
+		if pendingTx.From == tx.From && pendingTx.Nonce == tx.Nonce && pendingHash != txHash.Hex() {
+			return fmt.Errorf("TX from '%s' nonce '%d' is already pending as TX '%s'", tx.From, tx.Nonce, pendingHash)
+		}
+	}
+
 	txJson, err := json.Marshal(tx)
 	if err != nil {
 		return err
@@ -252,8 +384,98 @@ func (n *Node) AddPendingTX(tx database.Tx, fromPeer PeerNode) error {
 	return nil
 }
 
-func (n *Node) getPendingTXsAsArray() []database.Tx {
-	txs := make([]database.Tx, len(n.pendingTXs))
+// getNextAccountNonce returns the account's next nonce
+// taking into account its TXs still waiting in the mempool
+func (n *Node) getNextAccountNonce(account database.Account) uint {
+	nonce := n.state.GetNextAccountNonce(account)
+
+	for _, tx := range n.pendingTXs {
+		if tx.From == account && tx.Nonce >= nonce {
+			nonce = tx.Nonce + 1
+		}
+	}
+
+	return nonce
+}
+
+// getPendingBlockTXs orders each sender's pending TXs by nonce and selects
+// only those applying cleanly on top of the ones selected before.
+// TXs with a nonce gap wait in the mempool for the missing ones, and so do the TXs
+// beyond the genesis block size and TX count limits, the still locked TXs and the
+// TXs their sender can't pay for yet.
+func (n *Node) getPendingBlockTXs() []database.SignedTx {
+	gen := n.state.Genesis()
+	txs := n.getPendingTXsAsArray()
+
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].From != txs[j].From {
+			return txs[i].From < txs[j].From
+		}
+
+		return txs[i].Nonce < txs[j].Nonce
+	})
+
+	blockTXs := make([]database.SignedTx, 0, len(txs))
+	blockSize := uint64(database.BlockSizeOverhead(n.info.Account))
+	pendingState := n.state.NewPendingState()
+
+	for _, tx := range txs {
+		if uint64(len(blockTXs)) == gen.MaxBlockTXs {
+			break
+		}
+
+		// skipping a TX also skips the sender's next TXs, their nonce doesn't follow anymore
+		size := uint64(tx.EncodedSize())
+		if blockSize+size > gen.MaxBlockSize {
+			continue
+		}
+
+		if err := pendingState.ApplyTx(tx); err != nil {
+			continue
+		}
+
+		blockTXs = append(blockTXs, tx)
+		blockSize += size
+	}
+
+	return blockTXs
+}
+
+// checkPendingTxLock refuses TXs locked so far ahead the node
+// would keep mining empty blocks for them for too long
+func (n *Node) checkPendingTxLock(tx database.Tx) error {
+	maxHeight := n.state.NextBlockNumber() + maxPendingTxLockBlocks
+	if tx.LockHeight > maxHeight {
+		return fmt.Errorf("TX from '%s' is locked until block '%d', more than %d blocks ahead", tx.From, tx.LockHeight, maxPendingTxLockBlocks)
+	}
+
+	// the median time of an empty or stale chain lags behind the clock
+	now := uint64(time.Now().Unix())
+	if mtp := n.state.MedianTimePast(); mtp > now {
+		now = mtp
+	}
+
+	maxTime := now + maxPendingTxLockBlocks*n.state.Genesis().TargetBlockTime
+	if tx.LockTime > maxTime {
+		return fmt.Errorf("TX from '%s' is locked until time '%d', later than the time '%d' of %d blocks ahead", tx.From, tx.LockTime, maxTime, maxPendingTxLockBlocks)
+	}
+
+	return nil
+}
+
+// hasLockedPendingTXs tells if a pending TX waits for a later block height or median time
+func (n *Node) hasLockedPendingTXs() bool {
+	for _, tx := range n.pendingTXs {
+		if n.state.IsTxLocked(tx.Tx) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (n *Node) getPendingTXsAsArray() []database.SignedTx {
+	txs := make([]database.SignedTx, len(n.pendingTXs))
 
 	i := 0
 	for _, tx := range n.pendingTXs {
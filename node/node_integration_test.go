@@ -2,13 +2,16 @@ package node
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/disharjayanth/golangBlockchain/database"
 	"github.com/disharjayanth/golangBlockchain/fs"
+	"github.com/disharjayanth/golangBlockchain/wallet"
 )
 
 func TestNode_Run(t *testing.T) {
@@ -20,7 +23,8 @@ func TestNode_Run(t *testing.T) {
 
 	n := New(datadir, "127.0.0.1", 8000, database.NewAccount("andrej"), PeerNode{})
 
-	ctx, _ := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
 	err = n.Run(ctx)
 	if err.Error() != "http: Server closed" {
 		t.Fatal("node server was suppose to close after 5s")
@@ -45,9 +49,15 @@ func TestNode_Mining(t *testing.T) {
 		true,
 	)
 
+	// Andrej holds the genesis balance and signs the TXs
+	andrejKey, andrej, err := createTestGenesis(datadir, testDifficulty)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	// Construct a new Node instance and configure
 	// Andrej as a miner
-	n := New(datadir, nInfo.IP, nInfo.Port, database.NewAccount("andrej"), nInfo)
+	n := New(datadir, nInfo.IP, nInfo.Port, andrej, nInfo)
 
 	// Allow the mining to run for 30 mins, in the worst case
 	ctx, closeNode := context.WithTimeout(
@@ -58,8 +68,8 @@ func TestNode_Mining(t *testing.T) {
 	// Schedule a new TX in 3 seconds from now, in a separate thread
 	// because the n.Run() few lines below is a blocking call
 	go func() {
-		time.Sleep(time.Second * miningIntervalSeconds / 3)
-		tx := database.NewTx("andrej", "babayaga", 1, "")
+		time.Sleep(time.Second * database.DefaultMiningInterval / 3)
+		tx, _ := wallet.SignTx(database.NewTx(andrej, "babayaga", 1, 0, 1, ""), andrejKey)
 
 		_ = n.AddPendingTX(tx, nInfo)
 	}()
@@ -67,8 +77,8 @@ func TestNode_Mining(t *testing.T) {
 	// Schedule a new TX in 12 seconds from now simulating
 	// that it came in - while the first TX is being mined
 	go func() {
-		time.Sleep(time.Second*miningIntervalSeconds + 2)
-		tx := database.NewTx("andrej", "babayaga", 2, "")
+		time.Sleep(time.Second * (database.DefaultMiningInterval + 2))
+		tx, _ := wallet.SignTx(database.NewTx(andrej, "babayaga", 2, 0, 2, ""), andrejKey)
 
 		_ = n.AddPendingTX(tx, nInfo)
 	}()
@@ -114,7 +124,11 @@ func TestNode_MiningStopsOnNewSyncedBlock(t *testing.T) {
 		true,
 	)
 
-	andrejAcc := database.NewAccount("andrej")
+	// Mining must take long enough for the synced block to interrupt it
+	andrejKey, andrejAcc, err := createTestGenesis(datadir, testSlowDifficulty)
+	if err != nil {
+		t.Fatal(err)
+	}
 	babayagaAcc := database.NewAccount("babayaga")
 
 	n := New(datadir, nInfo.IP, nInfo.Port, babayagaAcc, nInfo)
@@ -122,14 +136,30 @@ func TestNode_MiningStopsOnNewSyncedBlock(t *testing.T) {
 	// Allow the test to run for 30 mins, in the worst case
 	ctx, closeNode := context.WithTimeout(context.Background(), time.Minute*30)
 
-	tx1 := database.NewTx("andrej", "babayaga", 1, "")
-	tx2 := database.NewTx("andrej", "babayaga", 2, "")
+	tx1, err := wallet.SignTx(database.NewTx(andrejAcc, babayagaAcc, 1, 1, 1, ""), andrejKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx2, err := wallet.SignTx(database.NewTx(andrejAcc, babayagaAcc, 2, 1, 2, ""), andrejKey)
+	if err != nil {
+		t.Fatal(err)
+	}
 	tx2Hash, _ := tx2.Hash()
 
 	// Pre-mine a valid block without running the `n.Run()`
 	// with Andrej as a miner who will receive the block reward,
 	// to simulate the block came on the fly from another peer
-	validPreMinedPb := NewPendingBlock(database.Hash{}, 0, andrejAcc, []database.Tx{tx1})
+	state, err := database.NewStateFromDisk(datadir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateRoot, err := state.NextStateRoot(andrejAcc, []database.SignedTx{tx1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Close()
+
+	validPreMinedPb := NewPendingBlock(database.Hash{}, 0, testSlowDifficulty, stateRoot, andrejAcc, []database.SignedTx{tx1})
 	validSyncedBlock, err := Mine(ctx, validPreMinedPb)
 	if err != nil {
 		t.Fatal(err)
@@ -137,48 +167,59 @@ func TestNode_MiningStopsOnNewSyncedBlock(t *testing.T) {
 
 	// Add 2 new TXs into the BabaYaga's node
 	go func() {
-		time.Sleep(time.Second * (miningIntervalSeconds - 2))
+		time.Sleep(time.Second * (database.DefaultMiningInterval - 2))
 
 		err := n.AddPendingTX(tx1, nInfo)
 		if err != nil {
-			t.Fatal(err)
+			t.Error(err)
+			return
 		}
 
 		err = n.AddPendingTX(tx2, nInfo)
 		if err != nil {
-			t.Fatal(err)
+			t.Error(err)
+			return
 		}
 	}()
 
 	// Once the BabaYaga is mining the block, simulate that
 	// Andrej mined the block with TX1 in it faster
 	go func() {
-		time.Sleep(time.Second * (miningIntervalSeconds + 2))
-		if !n.isMining {
-			t.Fatal("should be mining")
+		// the node may only pick the TXs up on its second mining tick
+		if !waitUntil(func() bool { return n.isMining }, time.Second*(2*database.DefaultMiningInterval+2)) {
+			t.Error("should be mining")
+			closeNode()
+			return
 		}
 		_, err := n.state.AddBlock(validSyncedBlock)
 		if err != nil {
-			t.Fatal(err)
+			t.Error(err)
+			closeNode()
+			return
 		}
 		// Mock the Andrej's block came from a network
 		n.newSyncedBlocks <- validSyncedBlock
 
 		time.Sleep(time.Second * 2)
 		if n.isMining {
-			t.Fatal("synced block should have canceled mining")
+			t.Error("synced block should have canceled mining")
+			closeNode()
+			return
 		}
 
 		// Mined TX1 by Andrej should be removed from the Mempool
 		_, onlyTX2IsPending := n.pendingTXs[tx2Hash.Hex()]
 
 		if len(n.pendingTXs) != 1 && !onlyTX2IsPending {
-			t.Fatal("synced block should have canceled mining of already mined TX")
+			t.Error("synced block should have canceled mining of already mined TX")
+			closeNode()
+			return
 		}
 
-		time.Sleep(time.Second * (miningIntervalSeconds + 2))
-		if !n.isMining {
-			t.Fatal("should be mining again the 1 TX not included in synced block")
+		// mining of the remaining TX may already be over by the time we look
+		isMiningAgain := func() bool { return n.isMining || n.state.LatestBlock().Header.Number == 1 }
+		if !waitUntil(isMiningAgain, time.Second*(database.DefaultMiningInterval+2)) {
+			t.Error("should be mining again the 1 TX not included in synced block")
 		}
 	}()
 
@@ -214,19 +255,23 @@ func TestNode_MiningStopsOnNewSyncedBlock(t *testing.T) {
 
 		// In TX1 Andrej transferred 1 TBB token to BabaYaga
 		// In TX2 Andrej transferred 2 TBB tokens to BabaYaga
-		expectedEndAndrejBalance := startingAndrejBalance - tx1.Value - tx2.Value + database.BlockReward
-		expectedEndBabaYagaBalance := startingBabaYagaBalance + tx1.Value + tx2.Value + database.BlockReward
+		// Each TX fee goes to the miner of the block including it,
+		// TX1 was mined by Andrej and TX2 by BabaYaga
+		tx1Cost, _ := tx1.Cost()
+		tx2Cost, _ := tx2.Cost()
+		expectedEndAndrejBalance := startingAndrejBalance - tx1Cost - tx2Cost + database.DefaultBlockReward + tx1.Fee
+		expectedEndBabaYagaBalance := startingBabaYagaBalance + tx1.Value + tx2.Value + database.DefaultBlockReward + tx2.Fee
 
 		if endAndrejBalance != expectedEndAndrejBalance {
-			t.Fatalf("Andrej expected end balance is %d not %d", expectedEndAndrejBalance, endAndrejBalance)
+			t.Errorf("Andrej expected end balance is %s not %s", expectedEndAndrejBalance, endAndrejBalance)
 		}
 		if endBabaYagaBalance != expectedEndBabaYagaBalance {
-			t.Fatalf("BabaYaga expected end balance is %d not %d", expectedEndBabaYagaBalance, endBabaYagaBalance)
+			t.Errorf("BabaYaga expected end balance is %s not %s", expectedEndBabaYagaBalance, endBabaYagaBalance)
 		}
-		t.Logf("Starting Andrej balance: %d", startingAndrejBalance)
-		t.Logf("Starting BabaYaga balance: %d", startingBabaYagaBalance)
-		t.Logf("Ending Andrej balance: %d", endAndrejBalance)
-		t.Logf("Ending BabaYaga balance: %d", endBabaYagaBalance)
+		t.Logf("Starting Andrej balance: %s", startingAndrejBalance)
+		t.Logf("Starting BabaYaga balance: %s", startingBabaYagaBalance)
+		t.Logf("Ending Andrej balance: %s", endAndrejBalance)
+		t.Logf("Ending BabaYaga balance: %s", endBabaYagaBalance)
 	}()
 
 	_ = n.Run(ctx)
@@ -243,3 +288,36 @@ func TestNode_MiningStopsOnNewSyncedBlock(t *testing.T) {
 func getTestDataDirPath() string {
 	return filepath.Join(os.TempDir(), ".tbb_test")
 }
+
+// createTestGenesis initializes the data dir with an easy to mine genesis
+// crediting all the TBB to a freshly generated account
+func createTestGenesis(dataDir string, difficulty uint32) (*secp256k1.PrivateKey, database.Account, error) {
+	privKey, err := wallet.NewRandomKey()
+	if err != nil {
+		return nil, database.Account(""), err
+	}
+
+	acc := database.PubKeyToAccount(privKey.PubKey())
+	genesis := fmt.Sprintf(`{"chain_id": "test-chain", "difficulty": %d, "balances": {"%s": 1000000}}`, difficulty, acc)
+
+	err = database.InitDataDirIfNotExists(dataDir, []byte(genesis))
+	if err != nil {
+		return nil, database.Account(""), err
+	}
+
+	return privKey, acc, nil
+}
+
+// waitUntil polls the condition until it holds or the timeout expires
+func waitUntil(condition func() bool, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	return condition()
+}
@@ -0,0 +1,256 @@
+package node
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/disharjayanth/golangBlockchain/database"
+	"github.com/disharjayanth/golangBlockchain/wallet"
+)
+
+func TestMinePendingTXsSkipsUnfundedTX(t *testing.T) {
+	n, andrejKey, andrej := newTestNode(t)
+
+	unfundedKey, err := wallet.NewRandomKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unfunded := database.PubKeyToAccount(unfundedKey.PubKey())
+
+	unfundedTx := signTestTx(t, unfundedKey, database.NewTx(unfunded, "babayaga", 1, 0, 1, ""))
+	validTx := signTestTx(t, andrejKey, database.NewTx(andrej, "babayaga", 1, 0, 1, ""))
+
+	for _, tx := range []database.SignedTx{unfundedTx, validTx} {
+		if err := n.AddPendingTX(tx, n.info); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := n.minePendingTXs(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if n.state.LatestBlockHash().IsEmpty() {
+		t.Fatal("the valid TX should be mined next to the unfunded one")
+	}
+
+	validHash, _ := validTx.Hash()
+	if _, isMined := n.state.GetTxLocation(validHash); !isMined {
+		t.Fatal("the valid TX should be in the mined block")
+	}
+
+	unfundedHash, _ := unfundedTx.Hash()
+	if _, isPending := n.pendingTXs[unfundedHash.Hex()]; !isPending {
+		t.Fatal("the unfunded TX should wait in the mempool")
+	}
+}
+
+func TestMinePendingTXsUnlocksLockedTX(t *testing.T) {
+	n, andrejKey, andrej := newTestNode(t)
+
+	tx := database.NewTx(andrej, "babayaga", 1, 0, 1, "")
+	tx.LockHeight = 2
+	tx.LockTime = uint64(time.Now().Unix()) + 1
+	lockedTx := signTestTx(t, andrejKey, tx)
+	lockedHash, _ := lockedTx.Hash()
+
+	if err := n.AddPendingTX(lockedTx, n.info); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		if _, isPending := n.pendingTXs[lockedHash.Hex()]; !isPending {
+			break
+		}
+
+		if err := n.minePendingTXs(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	location, isMined := n.state.GetTxLocation(lockedHash)
+	if !isMined {
+		t.Fatal("the locked TX should be mined once the node's empty blocks unlock it")
+	}
+
+	if location.BlockNumber < tx.LockHeight {
+		t.Fatalf("the TX locked until block '%d' was mined in block '%d'", tx.LockHeight, location.BlockNumber)
+	}
+
+	if err := n.minePendingTXs(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if n.state.LatestBlockHash() != location.BlockHash {
+		t.Fatal("no empty block should be mined once no locked TX is pending")
+	}
+}
+
+func TestAddPendingTXBoundsTxLock(t *testing.T) {
+	n, andrejKey, andrej := newTestNode(t)
+	horizon := uint64(maxPendingTxLockBlocks) * n.state.Genesis().TargetBlockTime
+
+	farHeight := database.NewTx(andrej, "babayaga", 1, 0, 1, "")
+	farHeight.LockHeight = n.state.NextBlockNumber() + maxPendingTxLockBlocks + 1
+
+	farTime := database.NewTx(andrej, "babayaga", 1, 0, 1, "")
+	farTime.LockTime = uint64(time.Now().Unix()) + horizon + 60
+
+	for _, tx := range []database.Tx{farHeight, farTime} {
+		if err := n.AddPendingTX(signTestTx(t, andrejKey, tx), n.info); err == nil || !strings.Contains(err.Error(), "is locked until") {
+			t.Fatalf("a TX locked beyond %d blocks should be rejected, got %v", maxPendingTxLockBlocks, err)
+		}
+	}
+
+	near := database.NewTx(andrej, "babayaga", 1, 0, 1, "")
+	near.LockHeight = n.state.NextBlockNumber() + maxPendingTxLockBlocks
+	near.LockTime = uint64(time.Now().Unix()) + horizon/2
+	if err := n.AddPendingTX(signTestTx(t, andrejKey, near), n.info); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetPendingBlockTXsOrdersByNonceAndHoldsGaps(t *testing.T) {
+	n, andrejKey, andrej := newTestNode(t)
+
+	for _, nonce := range []uint{3, 1, 5, 2} {
+		tx := signTestTx(t, andrejKey, database.NewTx(andrej, "babayaga", 1, 0, nonce, ""))
+		if err := n.AddPendingTX(tx, n.info); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	txs := n.getPendingBlockTXs()
+	if len(txs) != 3 {
+		t.Fatalf("the TX after the nonce 4 gap should wait, got %d TXs", len(txs))
+	}
+
+	for i, tx := range txs {
+		if tx.Nonce != uint(i+1) {
+			t.Fatalf("block TX %d has nonce '%d', TXs should be ordered by nonce", i, tx.Nonce)
+		}
+	}
+}
+
+func TestAddPendingTXRejectsUsedAndDuplicateNonces(t *testing.T) {
+	n, andrejKey, andrej := newTestNode(t)
+
+	tx := signTestTx(t, andrejKey, database.NewTx(andrej, "babayaga", 1, 0, 1, ""))
+	if err := n.AddPendingTX(tx, n.info); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.AddPendingTX(tx, n.info); err != nil {
+		t.Fatalf("adding the same TX again should be a no-op, got %v", err)
+	}
+
+	duplicate := signTestTx(t, andrejKey, database.NewTx(andrej, "babayaga", 2, 0, 1, ""))
+	if err := n.AddPendingTX(duplicate, n.info); err == nil || !strings.Contains(err.Error(), "already pending") {
+		t.Fatalf("a second TX of the same nonce should be rejected, got %v", err)
+	}
+
+	if err := n.minePendingTXs(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.AddPendingTX(duplicate, n.info); err == nil || !strings.Contains(err.Error(), "already used") {
+		t.Fatalf("a TX of a mined nonce should be rejected, got %v", err)
+	}
+}
+
+func TestMinePendingTXsEvictsStaleTXs(t *testing.T) {
+	n, andrejKey, andrej := newTestNode(t)
+
+	tx1 := signTestTx(t, andrejKey, database.NewTx(andrej, "babayaga", 1, 0, 1, ""))
+	tx2 := signTestTx(t, andrejKey, database.NewTx(andrej, "babayaga", 1, 0, 2, ""))
+	for _, tx := range []database.SignedTx{tx1, tx2} {
+		if err := n.AddPendingTX(tx, n.info); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// admission rejects it, it stands for a TX whose nonce a block mined elsewhere took
+	stale := signTestTx(t, andrejKey, database.NewTx(andrej, "caesar", 1, 0, 1, ""))
+	staleHash, _ := stale.Hash()
+	n.pendingTXs[staleHash.Hex()] = stale
+
+	if err := n.minePendingTXs(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(n.pendingTXs) != 0 {
+		t.Fatalf("the TX whose nonce was mined should be evicted, %d TXs are pending", len(n.pendingTXs))
+	}
+}
+
+func TestSyncPendingTXsFetchesBinaryMempool(t *testing.T) {
+	peerNode, andrejKey, andrej := newTestNode(t)
+	n, _, _ := newTestNode(t)
+
+	tx := signTestTx(t, andrejKey, database.NewTx(andrej, "babayaga", 1, 0, 1, ""))
+	if err := peerNode.AddPendingTX(tx, peerNode.info); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != endPointPendingTXs {
+			t.Errorf("pending TXs should be fetched from '%s', not '%s'", endPointPendingTXs, r.URL.Path)
+		}
+		pendingTXsHandler(w, r, peerNode)
+	}))
+	defer server.Close()
+
+	address := server.Listener.Addr().(*net.TCPAddr)
+	peer := NewPeerNode(address.IP.String(), uint64(address.Port), false, "", true)
+
+	if err := n.syncPendingTXs(peer); err != nil {
+		t.Fatal(err)
+	}
+
+	txHash, _ := tx.Hash()
+	if _, isPending := n.pendingTXs[txHash.Hex()]; !isPending || len(n.pendingTXs) != 1 {
+		t.Fatal("the peer's pending TX should be synced")
+	}
+}
+
+// newTestNode returns a node with its state loaded, without running it,
+// on a fresh data dir whose genesis credits the returned account
+func newTestNode(t *testing.T) (*Node, *secp256k1.PrivateKey, database.Account) {
+	dataDir, err := ioutil.TempDir("", "tbb_node_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dataDir) })
+
+	privKey, acc, err := createTestGenesis(dataDir, testDifficulty)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := New(dataDir, "127.0.0.1", 8085, acc, PeerNode{})
+
+	n.state, err = database.NewStateFromDisk(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { n.state.Close() })
+
+	return n, privKey, acc
+}
+
+func signTestTx(t *testing.T, privKey *secp256k1.PrivateKey, tx database.Tx) database.SignedTx {
+	signedTx, err := wallet.SignTx(tx, privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signedTx
+}
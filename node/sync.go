@@ -20,6 +20,7 @@ func (n *Node) sync(ctx context.Context) error {
 
 		case <-ctx.Done():
 			ticker.Stop()
+			return nil
 		}
 	}
 }
@@ -40,6 +41,12 @@ func (n *Node) doSync() {
 			continue
 		}
 
+		if status.ChainID != n.state.Genesis().ChainID {
+			fmt.Printf("Peer '%s' is on chain '%s' instead of '%s', it was removed from KnownPeers\n", peer.TcpAddress(), status.ChainID, n.state.Genesis().ChainID)
+			n.RemovePeer(peer)
+			continue
+		}
+
 		err = n.joinKnownPeers(peer)
 		if err != nil {
 			fmt.Printf("error in doSync func when joining new peers: %s\n", err)
@@ -59,7 +66,7 @@ func (n *Node) doSync() {
 			continue
 		}
 
-		err = n.syncPendingTXs(peer, status.PendingTXs)
+		err = n.syncPendingTXs(peer)
 		if err != nil {
 			fmt.Printf("error in syncPendingTXs func: %s\n", err)
 			continue
@@ -68,47 +75,99 @@ func (n *Node) doSync() {
 }
 
 func (n *Node) syncBlocks(peer PeerNode, status StatusRes) error {
-	localBlockNumber := n.state.LatestBlock().Header.Number
-
 	// If the peer has no blocks, ignore it
 	if status.Hash.IsEmpty() {
 		return nil
 	}
 
-	// If the peer has less blocks than us, ignore it
-	if status.Number < localBlockNumber {
-		return nil
-	}
-
-	// If it's the genesis block and we already synced it, ignore it
-	if status.Number == 0 && !n.state.LatestBlockHash().IsEmpty() {
+	// If the peer's chain doesn't have more work than ours, ignore it
+	if status.TotalWork == nil || status.TotalWork.Cmp(n.state.TotalWork()) <= 0 {
 		return nil
 	}
 
-	// Display found 1 new block if we sync the genesis block 0
-	newBlocksCount := status.Number - localBlockNumber
-	if localBlockNumber == 0 && status.Number == 0 {
-		newBlocksCount = 1
-	}
-	fmt.Printf("Found %d new blocks from Peer %s\n", newBlocksCount, peer.TcpAddress())
+	fmt.Printf("Found heavier chain with %d blocks from Peer %s\n", status.Number+1, peer.TcpAddress())
 
-	blocks, err := fetchBlocksFromPeer(peer, n.state.LatestBlockHash())
+	blocks, err := n.fetchMissingBlocksFromPeer(peer)
 	if err != nil {
 		return err
 	}
 
 	for _, block := range blocks {
+		blockHash, err := block.Hash()
+		if err != nil {
+			return err
+		}
+
+		isNew := !n.state.IsKnownBlock(blockHash)
+
 		_, err = n.state.AddBlock(block)
 		if err != nil {
 			return err
 		}
 
-		n.newSyncedBlocks <- block
+		if isNew && n.state.IsMainChainBlock(blockHash) {
+			n.newSyncedBlocks <- block
+		}
+
+		n.restoreOrphanedTXs()
+		n.evictStalePendingTXs()
 	}
 
 	return nil
 }
 
+// fetchMissingBlocksFromPeer looks for the latest of our main chain blocks the peer
+// also has, checking exponentially further back from our latest block,
+// and fetches the peer blocks after it. If the chains share no block at all
+// the whole peer chain is fetched.
+func (n *Node) fetchMissingBlocksFromPeer(peer PeerNode) ([]database.Block, error) {
+	if !n.LatestBlockHash().IsEmpty() {
+		tip := n.state.LatestBlock().Header.Number
+
+		for step := uint64(1); ; step *= 2 {
+			hash, _ := n.state.MainChainHash(tip)
+
+			blocks, err := fetchBlocksFromPeer(peer, hash)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(blocks) > 0 {
+				return blocks, nil
+			}
+
+			if tip == 0 {
+				break
+			}
+
+			if tip < step {
+				tip = 0
+			} else {
+				tip -= step
+			}
+		}
+	}
+
+	return fetchBlocksFromPeer(peer, database.Hash{})
+}
+
+// restoreOrphanedTXs puts the TXs of blocks dropped by a reorg back into the mempool
+func (n *Node) restoreOrphanedTXs() {
+	for _, tx := range n.state.PopOrphanedTXs() {
+		txHash, err := tx.Hash()
+		if err != nil {
+			continue
+		}
+
+		delete(n.archivedTXs, txHash.Hex())
+
+		err = n.AddPendingTX(tx, n.info)
+		if err != nil {
+			fmt.Printf("Orphaned TX '%s' dropped: %s\n", txHash.Hex(), err)
+		}
+	}
+}
+
 func (n *Node) syncKnownPeers(status StatusRes) error {
 	for _, statusPeer := range status.KnownPeers {
 		if !n.IsKnownPeer(statusPeer) {
@@ -120,11 +179,17 @@ func (n *Node) syncKnownPeers(status StatusRes) error {
 	return nil
 }
 
-func (n *Node) syncPendingTXs(peer PeerNode, txs []database.Tx) error {
+func (n *Node) syncPendingTXs(peer PeerNode) error {
+	txs, err := fetchPendingTXsFromPeer(peer)
+	if err != nil {
+		return err
+	}
+
 	for _, tx := range txs {
+		// a peer may still hold TXs we already mined, skip them and keep syncing the rest
 		err := n.AddPendingTX(tx, peer)
 		if err != nil {
-			return err
+			fmt.Printf("Pending TX from Peer %s rejected: %s\n", peer.TcpAddress(), err)
 		}
 	}
 
@@ -204,11 +269,26 @@ func fetchBlocksFromPeer(peer PeerNode, fromBlock database.Hash) ([]database.Blo
 		return nil, fmt.Errorf("error in fetchBlocksFromPeer func when making request to %s: %w", url, err)
 	}
 
-	syncRes := SyncRes{}
-	err = readRes(res, &syncRes)
+	blocksBin, err := readBinRes(res)
+	if err != nil {
+		return nil, err
+	}
+
+	return database.DecodeBlocks(blocksBin)
+}
+
+func fetchPendingTXsFromPeer(peer PeerNode) ([]database.SignedTx, error) {
+	url := fmt.Sprintf("http://%s%s", peer.TcpAddress(), endPointPendingTXs)
+
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error in fetchPendingTXsFromPeer func when making request to %s: %w", url, err)
+	}
+
+	txsBin, err := readBinRes(res)
 	if err != nil {
 		return nil, err
 	}
 
-	return syncRes.Blocks, nil
+	return database.DecodeTXs(txsBin)
 }
@@ -0,0 +1,156 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/disharjayanth/golangBlockchain/database"
+	"golang.org/x/crypto/scrypt"
+)
+
+const scryptN = 1 << 15
+const scryptR = 8
+const scryptP = 1
+const scryptKeyLen = 32
+
+type keyFile struct {
+	Address database.Account `json:"address"`
+	Crypto  keyFileCrypto    `json:"crypto"`
+}
+
+type keyFileCrypto struct {
+	KDF        string `json:"kdf"`
+	Salt       string `json:"salt"`
+	Nonce      string `json:"nonce"`
+	CipherText string `json:"ciphertext"`
+}
+
+// getKeyFilePath refuses anything but a 0x prefixed 20 bytes hex address,
+// the account may come from an HTTP request and must not escape the keystore dir
+func getKeyFilePath(keystoreDir string, acc database.Account) (string, error) {
+	if !isAddress(acc) {
+		return "", fmt.Errorf("account '%s' is not a 0x prefixed hex address of 20 bytes", acc)
+	}
+
+	return filepath.Join(keystoreDir, fmt.Sprintf("%s.json", acc)), nil
+}
+
+func isAddress(acc database.Account) bool {
+	if len(acc) != 42 || !strings.HasPrefix(string(acc), "0x") {
+		return false
+	}
+
+	_, err := hex.DecodeString(string(acc[2:]))
+	return err == nil
+}
+
+// storeKey encrypts the private key using AES-GCM with a scrypt derived password key
+func storeKey(keystoreDir string, acc database.Account, privKey *secp256k1.PrivateKey, password string) error {
+	path, err := getKeyFilePath(keystoreDir, acc)
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	gcm, err := newPasswordCipher(password, salt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	kf := keyFile{
+		Address: acc,
+		Crypto: keyFileCrypto{
+			KDF:        "scrypt",
+			Salt:       hex.EncodeToString(salt),
+			Nonce:      hex.EncodeToString(nonce),
+			CipherText: hex.EncodeToString(gcm.Seal(nil, nonce, privKey.Serialize(), []byte(acc))),
+		},
+	}
+
+	kfJSON, err := json.Marshal(kf)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(keystoreDir, 0700); err != nil {
+		return fmt.Errorf("error while creating keystore directory: %w", err)
+	}
+
+	return ioutil.WriteFile(path, kfJSON, 0600)
+}
+
+func loadKey(keystoreDir string, acc database.Account, password string) (*secp256k1.PrivateKey, error) {
+	path, err := getKeyFilePath(keystoreDir, acc)
+	if err != nil {
+		return nil, err
+	}
+
+	kfJSON, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("account '%s' not found in keystore: %w", acc, err)
+	}
+
+	var kf keyFile
+	if err := json.Unmarshal(kfJSON, &kf); err != nil {
+		return nil, fmt.Errorf("error while unmarshalling key file of account '%s': %w", acc, err)
+	}
+
+	salt, err := hex.DecodeString(kf.Crypto.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := hex.DecodeString(kf.Crypto.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	cipherText, err := hex.DecodeString(kf.Crypto.CipherText)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newPasswordCipher(password, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	keyBytes, err := gcm.Open(nil, nonce, cipherText, []byte(kf.Address))
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt key of account '%s', wrong password", acc)
+	}
+
+	return secp256k1.PrivKeyFromBytes(keyBytes), nil
+}
+
+func newPasswordCipher(password string, salt []byte) (cipher.AEAD, error) {
+	derivedKey, err := scrypt.Key([]byte(password), salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(derivedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
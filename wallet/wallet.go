@@ -0,0 +1,96 @@
+package wallet
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/disharjayanth/golangBlockchain/database"
+)
+
+const keystoreDirName = "keystore"
+
+func GetKeystoreDirPath(dataDir string) string {
+	return filepath.Join(dataDir, keystoreDirName)
+}
+
+func NewRandomKey() (*secp256k1.PrivateKey, error) {
+	return secp256k1.GeneratePrivateKey()
+}
+
+// NewKeystoreAccount generates a new key, stores it encrypted with the password
+// in the data dir keystore and returns the account address it controls
+func NewKeystoreAccount(dataDir string, password string) (database.Account, error) {
+	privKey, err := NewRandomKey()
+	if err != nil {
+		return database.Account(""), fmt.Errorf("error while generating new private key: %w", err)
+	}
+
+	acc := database.PubKeyToAccount(privKey.PubKey())
+
+	err = storeKey(GetKeystoreDirPath(dataDir), acc, privKey, password)
+	if err != nil {
+		return database.Account(""), err
+	}
+
+	return acc, nil
+}
+
+func SignTxWithKeystoreAccount(tx database.Tx, acc database.Account, password string, keystoreDir string) (database.SignedTx, error) {
+	privKey, err := loadKey(keystoreDir, acc, password)
+	if err != nil {
+		return database.SignedTx{}, err
+	}
+
+	return SignTx(tx, privKey)
+}
+
+func SignTx(tx database.Tx, privKey *secp256k1.PrivateKey) (database.SignedTx, error) {
+	txHash, err := tx.Hash()
+	if err != nil {
+		return database.SignedTx{}, err
+	}
+
+	return database.NewSignedTx(tx, Sign(txHash[:], privKey)), nil
+}
+
+// Sign produces a 65 bytes recoverable signature of the hash
+func Sign(hash []byte, privKey *secp256k1.PrivateKey) []byte {
+	return ecdsa.SignCompact(privKey, hash, false)
+}
+
+// GetKeystoreAccountPubKey returns the compressed public key of the account,
+// shared with the other keys of a multisig account
+func GetKeystoreAccountPubKey(acc database.Account, password string, keystoreDir string) ([]byte, error) {
+	privKey, err := loadKey(keystoreDir, acc, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return privKey.PubKey().SerializeCompressed(), nil
+}
+
+// SignMultisigTxWithKeystoreAccount adds the account signature to the multisig TX
+func SignMultisigTxWithKeystoreAccount(tx database.SignedTx, acc database.Account, password string, keystoreDir string) (database.SignedTx, error) {
+	privKey, err := loadKey(keystoreDir, acc, password)
+	if err != nil {
+		return database.SignedTx{}, err
+	}
+
+	return SignMultisigTx(tx, privKey)
+}
+
+func SignMultisigTx(tx database.SignedTx, privKey *secp256k1.PrivateKey) (database.SignedTx, error) {
+	txHash, err := tx.Tx.Hash()
+	if err != nil {
+		return database.SignedTx{}, err
+	}
+
+	tx.Sigs = append([][]byte(nil), tx.Sigs...)
+	if err := tx.AddMultisigSig(Sign(txHash[:], privKey)); err != nil {
+		return database.SignedTx{}, err
+	}
+
+	return tx, nil
+}
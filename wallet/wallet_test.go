@@ -0,0 +1,99 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/disharjayanth/golangBlockchain/database"
+	"github.com/disharjayanth/golangBlockchain/fs"
+)
+
+func TestSignTxWithKeystoreAccount(t *testing.T) {
+	dataDir := filepath.Join(os.TempDir(), ".tbb_wallet_test")
+	err := fs.RemoveDir(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.RemoveDir(dataDir)
+
+	andrej, err := NewKeystoreAccount(dataDir, "security123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := database.NewTx(andrej, database.NewAccount("babayaga"), 100, 0, 1, "")
+
+	signedTx, err := SignTxWithKeystoreAccount(tx, andrej, "security123", GetKeystoreDirPath(dataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := signedTx.IsAuthentic()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok {
+		t.Fatal("the TX was signed by 'from' account and should have been authentic")
+	}
+
+	_, err = SignTxWithKeystoreAccount(tx, andrej, "wrong", GetKeystoreDirPath(dataDir))
+	if err == nil {
+		t.Fatal("signing with a wrong password should fail")
+	}
+}
+
+func TestForgedTxIsNotAuthentic(t *testing.T) {
+	hacker, err := NewRandomKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	victim, err := NewRandomKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := database.NewTx(database.PubKeyToAccount(victim.PubKey()), database.PubKeyToAccount(hacker.PubKey()), 100, 0, 1, "")
+
+	forgedTx, err := SignTx(tx, hacker)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := forgedTx.IsAuthentic()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok {
+		t.Fatal("the TX 'from' attribute was forged and should not be authentic")
+	}
+}
+
+func TestKeystoreRefusesAccountsOutsideIt(t *testing.T) {
+	dataDir := filepath.Join(os.TempDir(), ".tbb_wallet_path_test")
+	if err := fs.RemoveDir(dataDir); err != nil {
+		t.Fatal(err)
+	}
+	defer fs.RemoveDir(dataDir)
+
+	if _, err := NewKeystoreAccount(dataDir, "security123"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dataDir, "outside.json"), []byte(`{}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := database.NewTx("andrej", "babayaga", 100, 0, 1, "")
+	for _, acc := range []database.Account{"../outside", "0x../../../../../../../../../../../outside", "andrej", database.Account("0xzz" + strings.Repeat("0", 38))} {
+		_, err := SignTxWithKeystoreAccount(tx, acc, "security123", GetKeystoreDirPath(dataDir))
+		if err == nil || !strings.Contains(err.Error(), "hex address") {
+			t.Fatalf("account '%s' should be refused before reading the keystore, got %v", acc, err)
+		}
+	}
+}